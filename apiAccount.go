@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/mailer"
+)
+
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// everything Chirpy holds about a user that is theirs to take away
+type accountExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Profile    User      `json:"profile"`
+	Chirps     []Chirp   `json:"chirps"`
+	Sessions   []Session `json:"sessions"`
+}
+
+// download the user's profile, chirps and sessions, as a ZIP archive or with ?format=json a single JSON document
+func (a *apiConfig) exportAccountHandler(response http.ResponseWriter, r *http.Request) {
+	principal := currentPrincipal(r)
+	user, err := a.store.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	chirps, err := a.store.GetChirpsByID(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	sessions, err := a.store.ListSessions(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	export := accountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    jsonReturnUser(user),
+		Chirps:     []Chirp{},
+		Sessions:   []Session{},
+	}
+	for _, chirp := range chirps {
+		export.Chirps = append(export.Chirps, jsonSafeChirp(chirp))
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, jsonSafeSession(session, principal.SessionID))
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%v", export.ExportedAt.Format("2006-01-02"))
+	if r.URL.Query().Get("format") == "json" {
+		response.Header().Set("Content-Type", "application/json")
+		response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v.json"`, filename))
+		jsonResponse(response, http.StatusOK, export, "Account exported")
+		return
+	}
+	response.Header().Set("Content-Type", "application/zip")
+	response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v.zip"`, filename))
+	response.WriteHeader(http.StatusOK)
+	err = writeExportArchive(response, export)
+	if err != nil {
+		// the status is already sent, so all that is left is to cut the download short
+		log.Printf("Error writing export for user %v: %v", user.ID, err)
+		return
+	}
+	log.Printf("Status: %v Account export sent for user %v", http.StatusOK, user.ID)
+}
+
+// write an export as one JSON file per kind of data
+func writeExportArchive(w io.Writer, export accountExport) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"sessions.json", export.Sessions},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return err
+		}
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// schedule the user's account for deletion once the grace period is over, sign them out everywhere
+// and revoke their personal access tokens. Signing in again before then cancels the deletion.
+func (a *apiConfig) deleteAccountHandler(response http.ResponseWriter, r *http.Request) {
+	type deleteAccount struct {
+		CurrentPassword string `json:"current_password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := deleteAccount{}
+	err := decoder.Decode(&params)
+	if err != nil && err != io.EOF {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	principal := currentPrincipal(r)
+	user, err := a.store.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if !a.confirmCredentialChange(response, r, principal, user, params.CurrentPassword) {
+		return
+	}
+	deletionAt := time.Now().Add(a.deletionGracePeriod)
+	err = a.store.ScheduleAccountDeletion(r.Context(), database.ScheduleAccountDeletionParams{
+		DeletionScheduledAt: sql.NullTime{Time: deletionAt, Valid: true},
+		ID:                  user.ID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.RevokeAllPersonalAccessTokensForUser(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.accessTokenDenylist.RevokeIssuedBefore(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	clearSessionCookies(response)
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account will be deleted",
+		Body: fmt.Sprintf("Your Chirpy account and everything in it will be permanently deleted on %v.\n\n"+
+			"Changed your mind? Sign in before then and the deletion is cancelled:\n%v/login\n",
+			deletionAt.Format(time.RFC1123), a.appURL),
+	}
+	go a.sendMail(message)
+	log.Printf("User %v scheduled their account for deletion at %v", user.ID, deletionAt)
+
+	type scheduledDeletion struct {
+		DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	}
+	jsonResponse(response, http.StatusAccepted, scheduledDeletion{DeletionScheduledAt: deletionAt}, "Account deletion scheduled")
+}
+
+// cancel a pending deletion when the user signs back in during the grace period
+func (a *apiConfig) cancelAccountDeletion(r *http.Request, user database.User) error {
+	if !user.DeletionScheduledAt.Valid {
+		return nil
+	}
+	cancelled, err := a.store.CancelAccountDeletion(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	if cancelled > 0 {
+		log.Printf("Cancelled scheduled deletion of user %v after they signed in", user.ID)
+	}
+	return nil
+}
+
+// hard delete accounts whose grace period is over; their data goes with them through ON DELETE CASCADE
+func (a *apiConfig) deleteScheduledAccounts(ctx context.Context) error {
+	deleted, err := a.store.DeleteScheduledAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %v accounts scheduled for deletion", deleted)
+	}
+	return nil
+}
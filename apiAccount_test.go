@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/store"
+)
+
+func TestAccountExportAndDeletion(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	api.createUser(t, "user@example.com", "correct horse")
+	session := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	if response := api.do(t, http.MethodPost, "/api/chirps", session.Token, map[string]string{"body": "hello"}); response.Code != http.StatusCreated {
+		t.Fatalf("posting a chirp failed: %v: %s", response.Code, response.Body)
+	}
+	response := api.do(t, http.MethodPost, "/api/tokens", session.Token, newToken{Name: "bot", Scopes: []string{auth.ScopeChirpsWrite}})
+	if response.Code != http.StatusCreated {
+		t.Fatalf("creating a token failed: %v: %s", response.Code, response.Body)
+	}
+	pat := "ApiKey " + decodeBody[PersonalAccessToken](t, response).Token
+	postWithToken := func() int {
+		return api.doAuthorized(t, http.MethodPost, "/api/chirps", pat, map[string]string{"body": "from a bot"}).Code
+	}
+	if code := postWithToken(); code != http.StatusCreated {
+		t.Fatalf("expected the token to work before deletion, got %v", code)
+	}
+
+	response = api.do(t, http.MethodPost, "/api/users/me/export?format=json", session.Token, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("export failed: %v: %s", response.Code, response.Body)
+	}
+	export := decodeBody[accountExport](t, response)
+	if export.Profile.Email != "user@example.com" || len(export.Chirps) != 2 || len(export.Sessions) != 1 {
+		t.Errorf("unexpected export %+v", export)
+	}
+
+	response = api.do(t, http.MethodDelete, "/api/users/me", session.Token, map[string]string{"current_password": "correct horse"})
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected deletion to be scheduled, got %v: %s", response.Code, response.Body)
+	}
+	api.waitForMessage(t, "Your Chirpy account will be deleted")
+	if code := postWithToken(); code != http.StatusUnauthorized {
+		t.Errorf("expected the token to stop working once deletion was scheduled, got %v", code)
+	}
+	if response := api.do(t, http.MethodPost, "/api/refresh", session.RefreshToken, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the session to be signed out, got %v", response.Code)
+	}
+
+	// signing back in during the grace period keeps the account, but not its revoked tokens
+	session = decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	user, _ := api.store.GetUserByID(ctx, session.ID)
+	if user.DeletionScheduledAt.Valid {
+		t.Error("expected signing in to cancel the deletion")
+	}
+	if code := postWithToken(); code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked token to stay revoked, got %v", code)
+	}
+
+	response = api.do(t, http.MethodDelete, "/api/users/me", session.Token, map[string]string{"current_password": "correct horse"})
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("expected deletion to be scheduled again, got %v: %s", response.Code, response.Body)
+	}
+	if err := api.deleteScheduledAccounts(ctx); err != nil {
+		t.Fatalf("deleteScheduledAccounts failed: %v", err)
+	}
+	if _, err := api.store.GetUserByID(ctx, session.ID); err != nil {
+		t.Fatalf("expected the account to survive its grace period, got %v", err)
+	}
+	api.store.(*store.Memory).Now = func() time.Time { return time.Now().Add(api.deletionGracePeriod + time.Minute) }
+	if err := api.deleteScheduledAccounts(ctx); err != nil {
+		t.Fatalf("deleteScheduledAccounts failed: %v", err)
+	}
+	if _, err := api.store.GetUserByID(ctx, session.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the account to be deleted once its grace period was over, got %v", err)
+	}
+	if chirps, _ := api.store.GetChirpsByID(ctx, session.ID); len(chirps) != 0 {
+		t.Errorf("expected the account's chirps to be deleted with it, got %v", chirps)
+	}
+}
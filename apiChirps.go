@@ -3,9 +3,11 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Lokee86/serverProject/internal/auth"
 	"github.com/Lokee86/serverProject/internal/database"
@@ -20,9 +22,9 @@ type Chirp struct {
 	UserID    uuid.UUID `json:"user_id"`
 }
 
+// a submitted chirp; its author is always the signed-in user, never taken from the body
 type handleChirp struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body string `json:"body"`
 }
 
 // fetches all chirps from table 'chirps' in database
@@ -42,9 +44,9 @@ func (a *apiConfig) fetchChirps(response http.ResponseWriter, r *http.Request) {
 		}
 
 		if sortQuery == "desc" {
-			chirps, err = a.databaseQueries.GetChirpsByIDDesc(r.Context(), uuidQuery)
+			chirps, err = a.store.GetChirpsByIDDesc(r.Context(), uuidQuery)
 		} else {
-			chirps, err = a.databaseQueries.GetChirpsByID(r.Context(), uuidQuery)
+			chirps, err = a.store.GetChirpsByID(r.Context(), uuidQuery)
 		}
 
 		if err == sql.ErrNoRows {
@@ -61,9 +63,9 @@ func (a *apiConfig) fetchChirps(response http.ResponseWriter, r *http.Request) {
 		jsonResponse(response, http.StatusOK, jsonSafeChirps, "Fetched chirps from provided ID")
 	} else {
 		if sortQuery == "desc" {
-			chirps, err = a.databaseQueries.GetAllChirpsDesc(r.Context())
+			chirps, err = a.store.GetAllChirpsDesc(r.Context())
 		} else {
-			chirps, err = a.databaseQueries.GetAllChirps(r.Context())
+			chirps, err = a.store.GetAllChirps(r.Context())
 		}
 
 		if err == sql.ErrNoRows {
@@ -85,7 +87,7 @@ func (a *apiConfig) fetchChirps(response http.ResponseWriter, r *http.Request) {
 func (a *apiConfig) fetchSingleChirp(response http.ResponseWriter, r *http.Request) {
 	idStr := extractIDString(response, r.URL.Path)
 
-	chirp, err := a.databaseQueries.SelectSingleChirp(r.Context(), idStr)
+	chirp, err := a.store.SelectSingleChirp(r.Context(), idStr)
 	if err == sql.ErrNoRows {
 		errorResponse(response, http.StatusNotFound, "Chirp not found")
 		return
@@ -97,19 +99,18 @@ func (a *apiConfig) fetchSingleChirp(response http.ResponseWriter, r *http.Reque
 	jsonResponse(response, http.StatusOK, jsonSafeChirp, "Single chirp query successful")
 }
 
-// validates length of submitted chirp
+// validates a submitted chirp against the author's plan
 func (a *apiConfig) validateChirp(response http.ResponseWriter, r *http.Request) {
 
 	checkedChirp := handleChirp{}
 
-	userToken, err := auth.GetBearerToken(r.Header)
+	user, err := a.store.GetUserByID(r.Context(), currentPrincipal(r).UserID)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	checkedChirp.UserID, err = auth.ValidateJWT(userToken)
-	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid access token")
+	if a.requiresVerifiedEmail(verifiedEmailForChirps) && !user.EmailVerifiedAt.Valid {
+		errorResponse(response, http.StatusForbidden, "Forbidden: Verify your email address before posting chirps")
 		return
 	}
 
@@ -120,58 +121,70 @@ func (a *apiConfig) validateChirp(response http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if len(checkedChirp.Body) > 140 {
-		errorResponse(response, http.StatusBadRequest, "Chirp is too long")
+	limits, err := a.limitsFor(r.Context(), user)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if utf8.RuneCountInString(checkedChirp.Body) > limits.MaxChirpLength {
+		errorResponse(response, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %v characters", limits.MaxChirpLength))
 		return
 	}
+	if limits.ChirpsPerHour > 0 {
+		posted, err := a.store.CountChirpsSince(r.Context(), database.CountChirpsSinceParams{
+			UserID:    user.ID,
+			CreatedAt: time.Now().Add(-time.Hour),
+		})
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+		if posted >= int64(limits.ChirpsPerHour) {
+			errorResponse(response, http.StatusTooManyRequests, fmt.Sprintf("Chirp limit of %v an hour reached, try again later", limits.ChirpsPerHour))
+			return
+		}
+	}
 	log.Println("Chirp validated")
 	checkProfanity(&checkedChirp.Body)
-	a.addChirp(response, checkedChirp, r)
+	a.addChirp(response, checkedChirp, user.ID, r)
 }
 
 // adds chirp to table 'chirps' in database
-func (a *apiConfig) addChirp(response http.ResponseWriter, checkedChirp handleChirp, r *http.Request) {
+func (a *apiConfig) addChirp(response http.ResponseWriter, checkedChirp handleChirp, userID uuid.UUID, r *http.Request) {
 	compatibleChirp := database.CreateChirpParams{
 		Body:   checkedChirp.Body,
-		UserID: checkedChirp.UserID,
+		UserID: userID,
 	}
-	chirp, err := a.databaseQueries.CreateChirp(r.Context(), compatibleChirp)
+	chirp, err := a.store.CreateChirp(r.Context(), compatibleChirp)
 	if err != nil {
 		internalError(response, err)
 		log.Println("Database Insertion Error")
 		return
 	}
 	jsonSafeChirp := jsonSafeChirp(chirp)
+	a.publishEvent(r.Context(), eventChirpCreated, chirp.UserID, jsonSafeChirp)
 	jsonResponse(response, http.StatusCreated, jsonSafeChirp, "Chirp added successfully")
 }
 
-// remove chirp from database if user is autorized
+// remove chirp from database if user is autorized; moderators may remove any chirp
 func (a *apiConfig) deleteChirp(response http.ResponseWriter, r *http.Request) {
-	userToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid access token")
-		return
-	}
-	userID, err := auth.ValidateJWT(userToken)
-	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid access token")
-		return
-	}
+	principal := currentPrincipal(r)
 	idStr := extractIDString(response, r.URL.Path)
-	chirp, err := a.databaseQueries.SelectSingleChirp(r.Context(), idStr)
+	chirp, err := a.store.SelectSingleChirp(r.Context(), idStr)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	if userID != chirp.UserID {
+	if principal.UserID != chirp.UserID && !principal.HasRole(auth.RoleModerator) {
 		errorResponse(response, http.StatusForbidden, "Forbidden: Not your chirp")
 		return
 	}
-	err = a.databaseQueries.DeleteChirp(r.Context(), idStr)
+	err = a.store.DeleteChirp(r.Context(), idStr)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
+	a.publishEvent(r.Context(), eventChirpDeleted, chirp.UserID, jsonSafeChirp(chirp))
 	noContentResponse(response, "Chirp deleted successfully")
 
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Lokee86/serverProject/internal/entitlements"
+)
+
+func TestChirpAuthorIsSignedInUser(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "author@example.com", "correct horse")
+	api.createUser(t, "victim@example.com", "correct horse")
+	author := decodeBody[User](t, api.login(t, "author@example.com", "correct horse"))
+	victim := decodeBody[User](t, api.login(t, "victim@example.com", "correct horse"))
+
+	response := api.do(t, http.MethodPost, "/api/chirps", author.Token, map[string]string{
+		"body":    "hello",
+		"user_id": victim.ID.String(),
+	})
+	if response.Code != http.StatusCreated {
+		t.Fatalf("expected the chirp to be created, got %v: %s", response.Code, response.Body)
+	}
+	if chirp := decodeBody[Chirp](t, response); chirp.UserID != author.ID {
+		t.Errorf("expected the chirp to belong to the signed-in user %v, got %v", author.ID, chirp.UserID)
+	}
+	if chirps, _ := api.store.GetChirpsByID(context.Background(), victim.ID); len(chirps) != 0 {
+		t.Errorf("expected no chirps under the user named in the body, got %v", chirps)
+	}
+}
+
+func TestChirpLengthCountsCharacters(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+	user := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	limit := entitlements.Defaults().For(entitlements.PlanFree).MaxChirpLength
+
+	// two bytes a character, so well over the limit in bytes
+	atLimit := strings.Repeat("é", limit)
+	if response := api.do(t, http.MethodPost, "/api/chirps", user.Token, map[string]string{"body": atLimit}); response.Code != http.StatusCreated {
+		t.Errorf("expected a %v character chirp to be accepted, got %v: %s", limit, response.Code, response.Body)
+	}
+	if response := api.do(t, http.MethodPost, "/api/chirps", user.Token, map[string]string{"body": atLimit + "🙂"}); response.Code != http.StatusBadRequest {
+		t.Errorf("expected a chirp one character over the limit to be rejected, got %v", response.Code)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,19 +12,53 @@ import (
 
 	"github.com/Lokee86/serverProject/internal/auth"
 	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/entitlements"
+	"github.com/Lokee86/serverProject/internal/mailer"
+	"github.com/Lokee86/serverProject/internal/oidc"
+	"github.com/Lokee86/serverProject/internal/store"
+	"github.com/Lokee86/serverProject/internal/webhook"
+	"github.com/google/uuid"
 )
 
 type apiConfig struct {
-	fileServerHits  atomic.Int32
-	databaseQueries *database.Queries
-	platform        string
-	polkaKey        string
+	fileServerHits atomic.Int32
+	store          store.Store
+	platform       string
+	// accepts the current Polka key and any previous one still being rotated out
+	polkaWebhook          *webhook.Verifier
+	polkaRequireSignature bool
+	webhookSender         *webhook.Sender
+	mailer                mailer.Mailer
+	publicURL             string
+	// the web app that emailed links open, which this server does not serve. It is expected
+	// to have a page at each path the emails name, such as /reset-password?token=, that
+	// hands the token to the matching API endpoint.
+	appURL string
+	// nil when single sign-on is not configured
+	oidcProvider *oidc.Provider
+	// actions limited to users with a verified email address
+	verifiedEmailRequired map[string]bool
+	loginAccountThrottle  *auth.LoginThrottle
+	loginIPThrottle       *auth.LoginThrottle
+	accountEmailLimiter   *auth.SendLimiter
+	ipEmailLimiter        *auth.SendLimiter
+	accessTokenDenylist   *accessTokenDenylist
+	// what each plan lets a user do
+	entitlements *entitlements.Engine
+	// how long a deleted account can still be recovered by signing in
+	deletionGracePeriod time.Duration
 }
 
 type token struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	CSRFToken    string `json:"csrf_token,omitempty"`
 }
 
+const accessTokenLifetime = 60 * time.Minute
+const refreshTokenLifetime = 60 * 24 * time.Hour
+const maxUserAgentLength = 512
+
 // increment file server hit counter
 func (a *apiConfig) serverHitCounter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -53,7 +89,7 @@ func (a *apiConfig) resetCounter(response http.ResponseWriter, r *http.Request)
 		return
 	}
 	a.fileServerHits.Store(0)
-	err := a.databaseQueries.ResetUsers(r.Context())
+	err := a.store.ResetUsers(r.Context())
 	if err != nil {
 		log.Printf("Error resetting 'users': %v", err)
 		http.Error(response, "failed to create user", http.StatusInternalServerError)
@@ -64,37 +100,173 @@ func (a *apiConfig) resetCounter(response http.ResponseWriter, r *http.Request)
 
 }
 
-// handle requests for access tokens through refresh endpoint
+// handle requests for access tokens through refresh endpoint, rotating the refresh token on every use
 func (a *apiConfig) refreshHandler(response http.ResponseWriter, r *http.Request) {
-	refreshToken, err := auth.GetBearerToken(r.Header)
+	refreshToken, fromCookie, err := auth.GetRefreshToken(r)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	fullRefreshToken, err := a.databaseQueries.GetRefreshToken(r.Context(), refreshToken)
-	if err == sql.ErrNoRows {
+	if fromCookie {
+		// checked before the token is used up, so a forged request cannot rotate the browser out of its session
+		stored, err := a.lookupRefreshToken(r.Context(), refreshToken)
+		if err == nil && !auth.ValidCSRFToken(stored.FamilyID, r.Header.Get(auth.CSRFHeader)) {
+			errorResponse(response, http.StatusForbidden, "Forbidden: Missing or invalid CSRF token")
+			return
+		}
+	}
+	fullRefreshToken, err := a.redeemRefreshToken(r, refreshToken, uuid.NullUUID{})
+	switch err {
+	case nil:
+	case errRefreshTokenNotFound:
 		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Refresh token not found")
 		return
-	} else if fullRefreshToken.RevokedAt.Valid {
+	case errRefreshTokenReused:
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Refresh token reuse detected")
+		return
+	case errRefreshTokenRevoked:
 		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Refresh token revoked")
 		return
-	} else if err != nil {
+	case errRefreshTokenExpired:
+		errorResponse(response, http.StatusUnauthorized, "Unauthorize: Refresh token expired")
+		return
+	default:
 		internalError(response, err)
 		return
 	}
-	if time.Now().After(fullRefreshToken.ExpiresAt) {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorize: Refresh token expired")
+	user, err := a.store.GetUserByID(r.Context(), fullRefreshToken.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	newRefreshToken, err := a.issueRefreshToken(r, user.ID, fullRefreshToken.FamilyID)
+	if err != nil {
+		internalError(response, err)
 		return
 	}
-	newAccessTokenValue, err := auth.MakeJWT(fullRefreshToken.UserID, 60*time.Minute)
+	newAccessTokenValue, err := auth.MakeJWT(user.ID, accessTokenLifetime,
+		auth.WithSessionID(fullRefreshToken.FamilyID), auth.WithRole(user.Role))
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	newAccessToken := token{
-		Token: newAccessTokenValue,
+	newTokens := token{
+		Token:        newAccessTokenValue,
+		RefreshToken: newRefreshToken,
+	}
+	if fromCookie {
+		a.setSessionCookies(response, newAccessTokenValue, newRefreshToken, fullRefreshToken.FamilyID)
+		newTokens = token{CSRFToken: auth.CSRFToken(fullRefreshToken.FamilyID)}
 	}
-	jsonResponse(response, http.StatusOK, newAccessToken, "Access token refresehd.")
+	jsonResponse(response, http.StatusOK, newTokens, "Access token refresehd.")
+}
+
+var (
+	errRefreshTokenNotFound = errors.New("refresh token not found")
+	errRefreshTokenReused   = errors.New("refresh token reuse detected")
+	errRefreshTokenRevoked  = errors.New("refresh token revoked")
+	errRefreshTokenExpired  = errors.New("refresh token expired")
+)
+
+// look up a presented refresh token and rotate it out, so the caller can issue its replacement in the same family.
+// Tokens issued to a different OAuth client, or to one when clientID is null, are treated as not found.
+func (a *apiConfig) redeemRefreshToken(r *http.Request, refreshToken string, clientID uuid.NullUUID) (database.RefreshToken, error) {
+	fullRefreshToken, err := a.lookupRefreshToken(r.Context(), refreshToken)
+	if err == sql.ErrNoRows {
+		return database.RefreshToken{}, errRefreshTokenNotFound
+	} else if err != nil {
+		return database.RefreshToken{}, err
+	}
+	if fullRefreshToken.ClientID != clientID {
+		return database.RefreshToken{}, errRefreshTokenNotFound
+	}
+	if fullRefreshToken.RotatedAt.Valid {
+		return database.RefreshToken{}, a.refreshTokenReused(r, fullRefreshToken)
+	}
+	if fullRefreshToken.RevokedAt.Valid {
+		return database.RefreshToken{}, errRefreshTokenRevoked
+	}
+	if time.Now().After(fullRefreshToken.ExpiresAt) {
+		return database.RefreshToken{}, errRefreshTokenExpired
+	}
+	rotated, err := a.store.RotateRefreshToken(r.Context(), fullRefreshToken.ID)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+	if rotated == 0 {
+		// another request rotated or revoked this token between the lookup and the update
+		return database.RefreshToken{}, a.refreshTokenReused(r, fullRefreshToken)
+	}
+	return fullRefreshToken, nil
+}
+
+// revoke every token in the family of a refresh token that was presented after being rotated
+func (a *apiConfig) refreshTokenReused(r *http.Request, reused database.RefreshToken) error {
+	log.Printf("SECURITY: Refresh token reuse detected for user %v from %v, revoking token family %v",
+		reused.UserID, r.RemoteAddr, reused.FamilyID)
+	err := a.store.RevokeRefreshTokenFamily(r.Context(), reused.FamilyID)
+	if err != nil {
+		return err
+	}
+	return errRefreshTokenReused
+}
+
+// create a refresh token in the given token family and store it with the requesting device's details
+func (a *apiConfig) issueRefreshToken(r *http.Request, userID, familyID uuid.UUID) (string, error) {
+	return a.issueClientRefreshToken(r, userID, familyID, uuid.NullUUID{}, nil)
+}
+
+// create a refresh token for an OAuth client, limited to the scopes the user granted it
+func (a *apiConfig) issueClientRefreshToken(r *http.Request, userID, familyID uuid.UUID, clientID uuid.NullUUID, scopes []string) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	refreshTokenParams := database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		FamilyID:  familyID,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IpAddress: clientIP(r),
+		ClientID:  clientID,
+		Scopes:    scopes,
+	}
+	err = a.store.CreateRefreshToken(r.Context(), refreshTokenParams)
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// find a stored refresh token from the raw value presented by a client
+func (a *apiConfig) lookupRefreshToken(ctx context.Context, refreshToken string) (database.RefreshToken, error) {
+	fullRefreshToken, err := a.store.GetRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err == sql.ErrNoRows {
+		// tokens issued before hashing was introduced are stored under an unkeyed hash
+		return a.store.GetRefreshToken(ctx, auth.LegacyTokenHash(refreshToken))
+	}
+	return fullRefreshToken, err
+}
+
+// validate an access token and check it has not been revoked since it was issued
+func (a *apiConfig) validateAccessToken(ctx context.Context, tokenString string) (uuid.UUID, *auth.Claims, error) {
+	claims, err := auth.ParseAccessToken(tokenString)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	revoked, err := a.accessTokenDenylist.Revoked(ctx, claims)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	if revoked {
+		return uuid.Nil, nil, errors.New("access token has been revoked")
+	}
+	return userID, claims, nil
 }
 
 // revoke refresh tokens
@@ -104,10 +276,18 @@ func (a *apiConfig) revokeHandler(response http.ResponseWriter, r *http.Request)
 		internalError(response, err)
 		return
 	}
-	err = a.databaseQueries.RevokeRefreshToken(r.Context(), refreshToken)
-	if err != nil {
+	fullRefreshToken, err := a.lookupRefreshToken(r.Context(), refreshToken)
+	if err == sql.ErrNoRows {
 		errorResponse(response, http.StatusUnauthorized, "Invalid refresh token")
 		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.RevokeRefreshToken(r.Context(), fullRefreshToken.ID)
+	if err != nil {
+		internalError(response, err)
+		return
 	}
 	noContentResponse(response, "Refresh token revoked")
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRefreshTokenReuse(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+	session := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+
+	response := api.do(t, http.MethodPost, "/api/refresh", session.RefreshToken, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected the refresh to succeed, got %v: %s", response.Code, response.Body)
+	}
+	rotated := decodeBody[token](t, response)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == session.RefreshToken {
+		t.Fatalf("expected a new refresh token, got %q", rotated.RefreshToken)
+	}
+
+	// presenting the rotated out token again means it leaked, so the whole session ends
+	response = api.do(t, http.MethodPost, "/api/refresh", session.RefreshToken, nil)
+	if response.Code != http.StatusUnauthorized || decodeBody[map[string]string](t, response)["error"] != "Unauthorized: Refresh token reuse detected" {
+		t.Fatalf("expected reuse to be detected, got %v: %s", response.Code, response.Body)
+	}
+	if response := api.do(t, http.MethodPost, "/api/refresh", rotated.RefreshToken, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the replacement token to be revoked along with its family, got %v", response.Code)
+	}
+
+	other := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	if response := api.do(t, http.MethodPost, "/api/refresh", other.RefreshToken, nil); response.Code != http.StatusOK {
+		t.Errorf("expected sessions in other families to be unaffected, got %v", response.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+// actions that can be limited to users with a verified email address, set through REQUIRE_VERIFIED_EMAIL
+const (
+	verifiedEmailForChirps = "chirp"
+	verifiedEmailForLogin  = "login"
+)
+
+// check that an email address is a bare, well formed address
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// report whether an action is restricted to users who have verified their email address
+func (a *apiConfig) requiresVerifiedEmail(action string) bool {
+	return a.verifiedEmailRequired[action]
+}
+
+// email a signed verification link for an address belonging to the user
+func (a *apiConfig) sendVerificationEmail(userID uuid.UUID, email string) error {
+	verificationToken, err := auth.MakeEmailVerificationToken(userID, email, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+	message := mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Confirm that this is your email address within the next %v:\n"+
+			"%v/verify-email?token=%v\n\n"+
+			"If you did not sign up for Chirpy, you can ignore this email.\n",
+			emailVerificationLifetime, a.appURL, verificationToken),
+	}
+	go a.sendMail(message)
+	return nil
+}
+
+// confirm an email address, or complete an email change, from a verification token
+func (a *apiConfig) verifyEmailHandler(response http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := token{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, email, err := auth.ValidateEmailVerificationToken(params.Token)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+	user, err := a.store.GetUserByID(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		errorResponse(response, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+
+	var verified int64
+	if user.Email == email {
+		verified, err = a.store.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+			ID:    user.ID,
+			Email: email,
+		})
+	} else {
+		verified, err = a.store.ConfirmPendingEmail(r.Context(), database.ConfirmPendingEmailParams{
+			ID:           user.ID,
+			PendingEmail: sql.NullString{String: email, Valid: true},
+		})
+	}
+	if isUniqueViolation(err) {
+		errorResponse(response, http.StatusConflict, "Email address already in use")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	if verified == 0 {
+		// the address changed again after this token was sent
+		errorResponse(response, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+	noContentResponse(response, "Email address verified")
+}
+
+// send a fresh verification link for the pending or still unverified email address
+func (a *apiConfig) resendVerificationHandler(response http.ResponseWriter, r *http.Request) {
+	user, err := a.store.GetUserByID(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	email := user.PendingEmail.String
+	if !user.PendingEmail.Valid {
+		if user.EmailVerifiedAt.Valid {
+			errorResponse(response, http.StatusBadRequest, "Email address already verified")
+			return
+		}
+		email = user.Email
+	}
+	err = a.sendVerificationEmail(user.ID, email)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	noContentResponse(response, "Verification email sent")
+}
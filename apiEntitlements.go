@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/entitlements"
+)
+
+type Entitlements struct {
+	Plan   string              `json:"plan"`
+	Limits entitlements.Limits `json:"limits"`
+}
+
+// show what the user's plan lets them do
+func (a *apiConfig) getEntitlementsHandler(response http.ResponseWriter, r *http.Request) {
+	user, err := a.store.GetUserByID(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	plan, err := a.userPlan(r.Context(), user)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusOK, Entitlements{Plan: plan, Limits: a.entitlements.For(plan)}, "Entitlements found")
+}
+
+// the limits of the plan a user is on
+func (a *apiConfig) limitsFor(ctx context.Context, user database.User) (entitlements.Limits, error) {
+	plan, err := a.userPlan(ctx, user)
+	if err != nil {
+		return entitlements.Limits{}, err
+	}
+	return a.entitlements.For(plan), nil
+}
+
+// Chirpy Red users are on the plan Polka last billed them for; everyone else is on the free plan
+func (a *apiConfig) userPlan(ctx context.Context, user database.User) (string, error) {
+	if !user.IsChirpyRed {
+		return entitlements.PlanFree, nil
+	}
+	subscription, err := a.store.GetSubscription(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		// upgraded before subscriptions were tracked
+		return entitlements.PlanChirpyRed, nil
+	} else if err != nil {
+		return "", err
+	}
+	return subscription.Plan, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// failed logins allowed per account and per client address before attempts are slowed down and then locked out
+const (
+	accountFreeAttempts     = 3
+	accountLockoutThreshold = 10
+	ipFreeAttempts          = 10
+	ipLockoutThreshold      = 50
+	loginLockoutDuration    = 15 * time.Minute
+)
+
+// sign in and password reset emails allowed per address and per client address, whether or not the address has an account
+const (
+	accountEmailLimit  = 1
+	accountEmailWindow = time.Minute
+	ipEmailLimit       = 10
+	ipEmailWindow      = time.Hour
+)
+
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// check both login throttles, responding 429 with Retry-After when either is blocking
+func (a *apiConfig) loginAllowed(response http.ResponseWriter, accountKey, ipKey string) bool {
+	wait, ok := a.loginAccountThrottle.Check(accountKey)
+	if ok {
+		wait, ok = a.loginIPThrottle.Check(ipKey)
+	}
+	if ok {
+		return true
+	}
+	tooManyRequests(response, wait, "Too many failed login attempts, try again later")
+	return false
+}
+
+// count an email sent to an account and from a client address, responding 429 with Retry-After when either is over its limit.
+// Neither is counted unless both allow it, so one address being limited does not use up the client address's allowance.
+func (a *apiConfig) accountEmailAllowed(response http.ResponseWriter, accountKey, ipKey string) bool {
+	wait, ok := a.accountEmailLimiter.Check(accountKey)
+	if ok {
+		wait, ok = a.ipEmailLimiter.Check(ipKey)
+	}
+	if !ok {
+		tooManyRequests(response, wait, "Too many emails requested, try again later")
+		return false
+	}
+	a.accountEmailLimiter.Record(accountKey)
+	a.ipEmailLimiter.Record(ipKey)
+	return true
+}
+
+func tooManyRequests(response http.ResponseWriter, wait time.Duration, msg string) {
+	response.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	errorResponse(response, http.StatusTooManyRequests, msg)
+}
+
+// record a failed login; unknown emails and wrong passwords get the same response
+func (a *apiConfig) loginFailed(response http.ResponseWriter, accountKey, ipKey string) {
+	a.loginAccountThrottle.Failure(accountKey)
+	a.loginIPThrottle.Failure(ipKey)
+	errorResponse(response, http.StatusUnauthorized, "Unauthorized: Incorrect email or password")
+}
+
+// lift the login lockout on an account and/or client address
+func (a *apiConfig) clearLockoutHandler(response http.ResponseWriter, r *http.Request) {
+	type clearLockout struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := clearLockout{}
+	err := decoder.Decode(&params)
+	if err != nil || (params.Email == "" && params.IP == "") {
+		errorResponse(response, http.StatusBadRequest, "An email or ip is required")
+		return
+	}
+	cleared := false
+	if params.Email != "" {
+		cleared = a.loginAccountThrottle.Clear(loginAccountKey(params.Email)) || cleared
+	}
+	if params.IP != "" {
+		cleared = a.loginIPThrottle.Clear("ip:"+params.IP) || cleared
+	}
+	if !cleared {
+		errorResponse(response, http.StatusNotFound, "No lockout found")
+		return
+	}
+	noContentResponse(response, "Login lockout cleared")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/mailer"
+)
+
+const magicLinkLifetime = 15 * time.Minute
+
+// email a single-use sign in link; responds the same whether or not the account exists
+func (a *apiConfig) magicLinkHandler(response http.ResponseWriter, r *http.Request) {
+	type magicLinkRequest struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := magicLinkRequest{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	// a locked out account or address cannot sidestep the lockout by asking for a link instead
+	accountKey, ipKey := loginAccountKey(params.Email), "ip:"+clientIP(r)
+	if !a.loginAllowed(response, accountKey, ipKey) || !a.accountEmailAllowed(response, accountKey, ipKey) {
+		return
+	}
+	user, err := a.store.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		noContentResponse(response, "Magic link requested for unknown email")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	magicLink, err := auth.MakeMagicLinkToken(user.ID, user.Email, magicLinkLifetime)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.CreateMagicLink(r.Context(), database.CreateMagicLinkParams{
+		TokenHash: auth.HashToken(magicLink),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(magicLinkLifetime),
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	// send in the background so response times do not reveal whether the account exists
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy sign in link",
+		Body: fmt.Sprintf("Someone asked to sign in to your Chirpy account.\n\n"+
+			"Sign in here within the next %v. The link works once:\n%v/login/magic?token=%v\n\n"+
+			"If this was not you, you can ignore this email.\n",
+			magicLinkLifetime, a.appURL, url.QueryEscape(magicLink)),
+	}
+	go a.sendMail(message)
+	noContentResponse(response, "Magic link sent")
+}
+
+// exchange a magic link for the same tokens a password login issues
+func (a *apiConfig) verifyMagicLinkHandler(response http.ResponseWriter, r *http.Request) {
+	type verifyMagicLink struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := verifyMagicLink{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	ipKey := "ip:" + clientIP(r)
+	if wait, ok := a.loginIPThrottle.Check(ipKey); !ok {
+		response.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		errorResponse(response, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return
+	}
+	userID, email, err := auth.ValidateMagicLinkToken(params.Token)
+	if err != nil {
+		a.magicLinkFailed(response, ipKey)
+		return
+	}
+	magicLink, err := a.store.UseMagicLink(r.Context(), auth.HashToken(params.Token))
+	if err == sql.ErrNoRows {
+		a.magicLinkFailed(response, ipKey)
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	user, err := a.store.GetUserByID(r.Context(), magicLink.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	// the link only works for the address it was sent to, so changing the account email voids it
+	if magicLink.UserID != userID || magicLink.Email != email || user.Email != email {
+		a.magicLinkFailed(response, ipKey)
+		return
+	}
+	err = a.store.InvalidateMagicLinks(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	a.loginAccountThrottle.Success(loginAccountKey(user.Email))
+	if !user.EmailVerifiedAt.Valid {
+		// following the link proved the user reads this mailbox
+		_, err = a.store.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+			ID:    user.ID,
+			Email: user.Email,
+		})
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+		user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	log.Printf("User %v signed in with a magic link", user.ID)
+	a.finishLogin(response, r, user)
+}
+
+// count a bad magic link against the client address, as a failed password would be
+func (a *apiConfig) magicLinkFailed(response http.ResponseWriter, ipKey string) {
+	a.loginIPThrottle.Failure(ipKey)
+	errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid or expired sign in link")
+}
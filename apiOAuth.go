@@ -0,0 +1,445 @@
+package main
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+const authorizationCodeLifetime = 10 * time.Minute
+
+// a validated request from a client for access to the user's account
+type authorizationRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// what the consent screen shows the user before they approve or deny a client
+type authorizationPrompt struct {
+	ClientID        uuid.UUID `json:"client_id"`
+	ClientName      string    `json:"client_name"`
+	RedirectURI     string    `json:"redirect_uri"`
+	Scopes          []string  `json:"scopes"`
+	ConsentRequired bool      `json:"consent_required"`
+}
+
+// where to send the user's browser once the authorization request is decided
+type authorizationDecision struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// RFC 6749 token endpoint response
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// RFC 7662 token introspection response
+type introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+}
+
+// describe an authorization request to the signed in user so they can decide on it
+func (a *apiConfig) authorizeHandler(response http.ResponseWriter, r *http.Request) {
+	request, ok := a.parseAuthorizationRequest(response, r)
+	if !ok {
+		return
+	}
+	consent, err := a.store.GetOAuthConsent(r.Context(), database.GetOAuthConsentParams{
+		UserID:   currentPrincipal(r).UserID,
+		ClientID: request.client.ID,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		internalError(response, err)
+		return
+	}
+	prompt := authorizationPrompt{
+		ClientID:        request.client.ID,
+		ClientName:      request.client.Name,
+		RedirectURI:     request.redirectURI,
+		Scopes:          request.scopes,
+		ConsentRequired: err == sql.ErrNoRows || !containsAll(consent.Scopes, request.scopes),
+	}
+	jsonResponse(response, http.StatusOK, prompt, "Authorization request described")
+}
+
+// record the user's decision on an authorization request and issue an authorization code when they approve
+func (a *apiConfig) authorizeDecisionHandler(response http.ResponseWriter, r *http.Request) {
+	request, ok := a.parseAuthorizationRequest(response, r)
+	if !ok {
+		return
+	}
+	if r.FormValue("approve") != "true" {
+		redirectTo := authorizationRedirect(request, url.Values{"error": {"access_denied"}})
+		jsonResponse(response, http.StatusOK, authorizationDecision{RedirectTo: redirectTo}, "Authorization denied")
+		return
+	}
+	userID := currentPrincipal(r).UserID
+	err := a.store.UpsertOAuthConsent(r.Context(), database.UpsertOAuthConsentParams{
+		UserID:   userID,
+		ClientID: request.client.ID,
+		Scopes:   request.scopes,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	code, err := auth.MakeOpaqueToken()
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      request.client.ID,
+		UserID:        userID,
+		RedirectUri:   request.redirectURI,
+		Scopes:        request.scopes,
+		CodeChallenge: request.codeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(authorizationCodeLifetime),
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	redirectTo := authorizationRedirect(request, url.Values{"code": {code}})
+	jsonResponse(response, http.StatusOK, authorizationDecision{RedirectTo: redirectTo}, "Authorization code issued")
+}
+
+// validate the parameters of an authorization request. Until the client and redirect URI are known
+// to be good errors are reported to the user alone; after that they carry the redirect back to the client.
+func (a *apiConfig) parseAuthorizationRequest(response http.ResponseWriter, r *http.Request) (authorizationRequest, bool) {
+	clientID, err := uuid.Parse(r.FormValue("client_id"))
+	if err != nil {
+		oauthError(response, http.StatusBadRequest, "invalid_client", "Unknown client")
+		return authorizationRequest{}, false
+	}
+	client, err := a.store.GetOAuthClient(r.Context(), clientID)
+	if err == sql.ErrNoRows {
+		oauthError(response, http.StatusBadRequest, "invalid_client", "Unknown client")
+		return authorizationRequest{}, false
+	} else if err != nil {
+		internalError(response, err)
+		return authorizationRequest{}, false
+	}
+	request := authorizationRequest{
+		client:        client,
+		redirectURI:   r.FormValue("redirect_uri"),
+		state:         r.FormValue("state"),
+		codeChallenge: r.FormValue("code_challenge"),
+	}
+	if request.redirectURI == "" && len(client.RedirectUris) == 1 {
+		request.redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, request.redirectURI) {
+		oauthError(response, http.StatusBadRequest, "invalid_request", "Redirect URI is not registered for this client")
+		return authorizationRequest{}, false
+	}
+
+	errorCode, description := "", ""
+	request.scopes = strings.Fields(r.FormValue("scope"))
+	switch {
+	case r.FormValue("response_type") != "code":
+		errorCode, description = "unsupported_response_type", "Only the code response type is supported"
+	case r.FormValue("code_challenge_method") != "S256" || !auth.ValidCodeChallenge(request.codeChallenge):
+		errorCode, description = "invalid_request", "A PKCE code challenge using S256 is required"
+	case len(request.scopes) == 0 || !containsAll(client.Scopes, request.scopes):
+		errorCode, description = "invalid_scope", "Requested scopes are missing or not allowed for this client"
+	}
+	if errorCode != "" {
+		type redirectedError struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+			RedirectTo  string `json:"redirect_to"`
+		}
+		redirectTo := authorizationRedirect(request, url.Values{"error": {errorCode}, "error_description": {description}})
+		jsonResponse(response, http.StatusBadRequest, redirectedError{errorCode, description, redirectTo}, description)
+		return authorizationRequest{}, false
+	}
+	slices.Sort(request.scopes)
+	request.scopes = slices.Compact(request.scopes)
+	return request, true
+}
+
+// exchange an authorization code or refresh token for tokens
+func (a *apiConfig) oauthTokenHandler(response http.ResponseWriter, r *http.Request) {
+	response.Header().Set("Cache-Control", "no-store")
+	client, ok := a.authenticateClient(response, r)
+	if !ok {
+		return
+	}
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		a.authorizationCodeGrant(response, r, client)
+	case "refresh_token":
+		a.refreshTokenGrant(response, r, client)
+	default:
+		oauthError(response, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code and refresh_token grants are supported")
+	}
+}
+
+func (a *apiConfig) authorizationCodeGrant(response http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	codeHash := auth.HashToken(r.PostFormValue("code"))
+	familyID := uuid.New()
+	code, err := a.store.RedeemAuthorizationCode(r.Context(), database.RedeemAuthorizationCodeParams{
+		CodeHash: codeHash,
+		FamilyID: uuid.NullUUID{UUID: familyID, Valid: true},
+	})
+	if err == sql.ErrNoRows {
+		a.authorizationCodeReused(r, codeHash)
+		oauthError(response, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	if code.ClientID != client.ID || code.RedirectUri != r.PostFormValue("redirect_uri") ||
+		time.Now().After(code.ExpiresAt) || !auth.VerifyPKCE(r.PostFormValue("code_verifier"), code.CodeChallenge) {
+		oauthError(response, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+	a.issueClientTokens(response, r, client, code.UserID, familyID, code.Scopes, code.Scopes)
+}
+
+// a code presented twice may have been intercepted, so everything issued from its first use is revoked
+func (a *apiConfig) authorizationCodeReused(r *http.Request, codeHash string) {
+	code, err := a.store.GetAuthorizationCode(r.Context(), codeHash)
+	if err != nil || !code.FamilyID.Valid {
+		return
+	}
+	log.Printf("SECURITY: Authorization code reuse detected for user %v and client %v from %v, revoking token family %v",
+		code.UserID, code.ClientID, r.RemoteAddr, code.FamilyID.UUID)
+	err = a.store.RevokeRefreshTokenFamily(r.Context(), code.FamilyID.UUID)
+	if err != nil {
+		log.Printf("Error revoking token family %v: %v", code.FamilyID.UUID, err)
+	}
+}
+
+func (a *apiConfig) refreshTokenGrant(response http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	refreshToken, err := a.redeemRefreshToken(r, r.PostFormValue("refresh_token"), uuid.NullUUID{UUID: client.ID, Valid: true})
+	switch err {
+	case nil:
+	case errRefreshTokenNotFound, errRefreshTokenReused, errRefreshTokenRevoked, errRefreshTokenExpired:
+		oauthError(response, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	default:
+		internalError(response, err)
+		return
+	}
+	// the access token may be narrowed, but the grant itself keeps its original scopes
+	scopes := refreshToken.Scopes
+	if requested := strings.Fields(r.PostFormValue("scope")); len(requested) > 0 {
+		if !containsAll(refreshToken.Scopes, requested) {
+			oauthError(response, http.StatusBadRequest, "invalid_scope", "Requested scopes exceed the original grant")
+			return
+		}
+		scopes = requested
+	}
+	a.issueClientTokens(response, r, client, refreshToken.UserID, refreshToken.FamilyID, refreshToken.Scopes, scopes)
+}
+
+// issue a refresh token carrying grantScopes and an access token limited to accessScopes
+func (a *apiConfig) issueClientTokens(response http.ResponseWriter, r *http.Request, client database.OauthClient, userID, familyID uuid.UUID, grantScopes, accessScopes []string) {
+	clientID := uuid.NullUUID{UUID: client.ID, Valid: true}
+	refreshToken, err := a.issueClientRefreshToken(r, userID, familyID, clientID, grantScopes)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	accessToken, err := auth.MakeJWT(userID, accessTokenLifetime,
+		auth.WithSessionID(familyID), auth.WithClientID(client.ID), auth.WithScopes(accessScopes))
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	tokens := oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(accessScopes, " "),
+	}
+	jsonResponse(response, http.StatusOK, tokens, "OAuth tokens issued")
+}
+
+// RFC 7009 revocation: revoking a refresh token ends the whole grant, revoking an access token denylists it.
+// Unknown tokens and tokens issued to other clients are ignored, as the RFC requires.
+func (a *apiConfig) oauthRevokeHandler(response http.ResponseWriter, r *http.Request) {
+	client, ok := a.authenticateClient(response, r)
+	if !ok {
+		return
+	}
+	rawToken := r.PostFormValue("token")
+	refreshToken, err := a.lookupRefreshToken(r.Context(), rawToken)
+	if err == nil && refreshToken.ClientID.UUID == client.ID {
+		err = a.store.RevokeRefreshTokenFamily(r.Context(), refreshToken.FamilyID)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+	} else if err != nil && err != sql.ErrNoRows {
+		internalError(response, err)
+		return
+	} else if _, claims, err := a.validateAccessToken(r.Context(), rawToken); err == nil && claims.ClientID == client.ID.String() {
+		principal, err := auth.PrincipalFromClaims(claims)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+		err = a.accessTokenDenylist.Deny(r.Context(), principal)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+	}
+	response.WriteHeader(http.StatusOK)
+	log.Printf("Status: %v OAuth token revocation processed", http.StatusOK)
+}
+
+// RFC 7662 introspection, limited to tokens issued to the calling client
+func (a *apiConfig) oauthIntrospectHandler(response http.ResponseWriter, r *http.Request) {
+	client, ok := a.authenticateClient(response, r)
+	if !ok {
+		return
+	}
+	rawToken := r.PostFormValue("token")
+	if _, claims, err := a.validateAccessToken(r.Context(), rawToken); err == nil {
+		if claims.ClientID != client.ID.String() {
+			jsonResponse(response, http.StatusOK, introspection{}, "Token introspected")
+			return
+		}
+		result := introspection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Subject:   claims.Subject,
+			TokenType: "access_token",
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			TokenID:   claims.ID,
+		}
+		if claims.IssuedAt != nil {
+			result.IssuedAt = claims.IssuedAt.Unix()
+		}
+		jsonResponse(response, http.StatusOK, result, "Token introspected")
+		return
+	}
+	refreshToken, err := a.lookupRefreshToken(r.Context(), rawToken)
+	if err != nil && err != sql.ErrNoRows {
+		internalError(response, err)
+		return
+	}
+	if err == sql.ErrNoRows || refreshToken.ClientID.UUID != client.ID || refreshToken.RevokedAt.Valid || time.Now().After(refreshToken.ExpiresAt) {
+		jsonResponse(response, http.StatusOK, introspection{}, "Token introspected")
+		return
+	}
+	result := introspection{
+		Active:    true,
+		Scope:     strings.Join(refreshToken.Scopes, " "),
+		ClientID:  client.ID.String(),
+		Subject:   refreshToken.UserID.String(),
+		TokenType: "refresh_token",
+		ExpiresAt: refreshToken.ExpiresAt.Unix(),
+		IssuedAt:  refreshToken.CreatedAt.Unix(),
+	}
+	jsonResponse(response, http.StatusOK, result, "Token introspected")
+}
+
+// identify the client calling a token endpoint, by HTTP Basic or form credentials.
+// Confidential clients must present their secret; public clients must not have one.
+func (a *apiConfig) authenticateClient(response http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	rawClientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1: credentials are form encoded before going into the header
+		rawClientID, _ = url.QueryUnescape(rawClientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		rawClientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	client, err := a.lookupClient(r, rawClientID, secret)
+	if err != nil {
+		log.Printf("Rejected OAuth client credentials: %v", err)
+		if basic {
+			response.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		oauthError(response, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+func (a *apiConfig) lookupClient(r *http.Request, rawClientID, secret string) (database.OauthClient, error) {
+	clientID, err := uuid.Parse(rawClientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	client, err := a.store.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if !client.SecretHash.Valid {
+		if secret != "" {
+			return database.OauthClient{}, errors.New("public client presented a secret")
+		}
+		return client, nil
+	}
+	if !hmac.Equal([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) {
+		return database.OauthClient{}, errors.New("client secret mismatch")
+	}
+	return client, nil
+}
+
+// send an RFC 6749 error response
+func oauthError(response http.ResponseWriter, code int, errorCode, description string) {
+	type oauthErr struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	jsonResponse(response, code, oauthErr{Error: errorCode, Description: description}, description)
+}
+
+// the client's redirect URI with params and the request's state added
+func authorizationRedirect(request authorizationRequest, params url.Values) string {
+	redirectURI, _ := url.Parse(request.redirectURI)
+	query := redirectURI.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.state != "" {
+		query.Set("state", request.state)
+	}
+	redirectURI.RawQuery = query.Encode()
+	return redirectURI.String()
+}
+
+// report whether every one of wanted is in granted
+func containsAll(granted, wanted []string) bool {
+	for _, scope := range wanted {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+)
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+	user := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+
+	const redirectURI = "https://app.example.com/callback"
+	response := api.do(t, http.MethodPost, "/api/oauth/clients", user.Token, newOAuthClient{
+		Name:         "Example App",
+		RedirectURIs: []string{redirectURI},
+		Scopes:       []string{auth.ScopeChirpsRead, auth.ScopeChirpsWrite},
+		Public:       true,
+	})
+	if response.Code != http.StatusCreated {
+		t.Fatalf("registering the client failed: %v: %s", response.Code, response.Body)
+	}
+	client := decodeBody[OAuthClient](t, response)
+
+	// token endpoints take form bodies and authenticate this public client by its ID alone
+	postForm := func(path string, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		form.Set("client_id", client.ID.String())
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		api.handler.ServeHTTP(response, req)
+		return response
+	}
+	verifier, err := auth.MakeOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizeURL := "/oauth/authorize?" + url.Values{
+		"client_id":             {client.ID.String()},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {auth.ScopeChirpsRead},
+		"state":                 {"opaque-state"},
+		"code_challenge":        {auth.S256CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	issueCode := func() string {
+		t.Helper()
+		response := api.do(t, http.MethodPost, authorizeURL+"&approve=true", user.Token, nil)
+		if response.Code != http.StatusOK {
+			t.Fatalf("approving the request failed: %v: %s", response.Code, response.Body)
+		}
+		redirectTo, err := url.Parse(decodeBody[authorizationDecision](t, response).RedirectTo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redirectTo.Query().Get("state") != "opaque-state" || redirectTo.Query().Get("code") == "" {
+			t.Fatalf("expected a code and the state in the redirect, got %v", redirectTo)
+		}
+		return redirectTo.Query().Get("code")
+	}
+
+	response = api.do(t, http.MethodGet, authorizeURL, user.Token, nil)
+	if response.Code != http.StatusOK || !decodeBody[authorizationPrompt](t, response).ConsentRequired {
+		t.Fatalf("expected the first request to ask for consent, got %v: %s", response.Code, response.Body)
+	}
+	exchange := func(code, verifier string) *httptest.ResponseRecorder {
+		t.Helper()
+		return postForm("/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+	}
+	if response := exchange(issueCode(), "wrong verifier"); response.Code != http.StatusBadRequest {
+		t.Errorf("expected a code with the wrong verifier to be rejected, got %v: %s", response.Code, response.Body)
+	}
+	response = exchange(issueCode(), verifier)
+	if response.Code != http.StatusOK {
+		t.Fatalf("exchanging the code failed: %v: %s", response.Code, response.Body)
+	}
+	tokens := decodeBody[oauthTokenResponse](t, response)
+	if tokens.Scope != auth.ScopeChirpsRead || tokens.RefreshToken == "" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+
+	introspect := func(token string) introspection {
+		t.Helper()
+		response := postForm("/oauth/introspect", url.Values{"token": {token}})
+		if response.Code != http.StatusOK {
+			t.Fatalf("introspection failed: %v: %s", response.Code, response.Body)
+		}
+		return decodeBody[introspection](t, response)
+	}
+	if result := introspect(tokens.AccessToken); !result.Active || result.Subject != user.ID.String() || result.Scope != auth.ScopeChirpsRead {
+		t.Errorf("unexpected introspection of the access token %+v", result)
+	}
+
+	response = postForm("/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+	if response.Code != http.StatusOK {
+		t.Fatalf("refreshing failed: %v: %s", response.Code, response.Body)
+	}
+	refreshed := decodeBody[oauthTokenResponse](t, response)
+	if introspect(tokens.RefreshToken).Active || !introspect(refreshed.RefreshToken).Active {
+		t.Errorf("expected the refresh token to be rotated")
+	}
+
+	for _, token := range []string{refreshed.AccessToken, refreshed.RefreshToken} {
+		if response := postForm("/oauth/revoke", url.Values{"token": {token}}); response.Code != http.StatusOK {
+			t.Fatalf("revoking failed: %v: %s", response.Code, response.Body)
+		}
+		if introspect(token).Active {
+			t.Errorf("expected a revoked token to be inactive")
+		}
+	}
+
+	// a code approved before consent is withdrawn must not be exchangeable after it
+	unused := issueCode()
+	if response := api.do(t, http.MethodDelete, "/api/oauth/consents/"+client.ID.String(), user.Token, nil); response.Code != http.StatusNoContent {
+		t.Fatalf("revoking consent failed: %v: %s", response.Code, response.Body)
+	}
+	if response := exchange(unused, verifier); response.Code != http.StatusBadRequest {
+		t.Errorf("expected a code issued before consent was revoked to be rejected, got %v: %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/oidc"
+)
+
+const oidcLoginCookie = "chirpy_oidc_login"
+const oidcLoginLifetime = 10 * time.Minute
+
+var errUnverifiedIdentity = errors.New("identity provider did not vouch for an email address")
+
+// send the user to the identity provider to sign in
+func (a *apiConfig) oidcLoginHandler(response http.ResponseWriter, r *http.Request) {
+	if a.oidcProvider == nil {
+		errorResponse(response, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+	login, loginToken, err := auth.MakeOIDCLogin(cookieSessionRequested(r), oidcLoginLifetime)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	authURL, err := a.oidcProvider.AuthCodeURL(r.Context(), login.State, login.Nonce, auth.S256CodeChallenge(login.CodeVerifier))
+	if err != nil {
+		log.Printf("Error contacting identity provider: %v", err)
+		errorResponse(response, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+	// the login is bound to this browser, so a callback started elsewhere cannot complete it
+	http.SetCookie(response, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    loginToken,
+		Path:     "/api/login/oidc",
+		MaxAge:   int(oidcLoginLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(response, r, authURL, http.StatusFound)
+	log.Printf("Status: %v Redirected to identity provider", http.StatusFound)
+}
+
+// finish signing in with the identity provider, linking or creating the user's account
+func (a *apiConfig) oidcCallbackHandler(response http.ResponseWriter, r *http.Request) {
+	if a.oidcProvider == nil {
+		errorResponse(response, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil {
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Sign in was not started from this browser")
+		return
+	}
+	http.SetCookie(response, &http.Cookie{Name: oidcLoginCookie, Path: "/api/login/oidc", MaxAge: -1})
+	login, err := auth.ValidateOIDCLogin(cookie.Value)
+	if err != nil {
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Sign in expired, please try again")
+		return
+	}
+	if providerError := r.URL.Query().Get("error"); providerError != "" {
+		log.Printf("Identity provider refused sign in: %v", providerError)
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Sign in was refused by the identity provider")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("state")), []byte(login.State)) != 1 {
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid sign in state")
+		return
+	}
+	idToken, err := a.oidcProvider.Exchange(r.Context(), r.URL.Query().Get("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("Rejected sign in from identity provider: %v", err)
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Could not verify sign in with the identity provider")
+		return
+	}
+	user, err := a.oidcUser(r, idToken)
+	if err == errUnverifiedIdentity {
+		errorResponse(response, http.StatusForbidden, "Forbidden: The identity provider did not supply a verified email address")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	if login.CookieSession {
+		r = withCookieSession(r)
+	}
+	a.finishLogin(response, r, user)
+}
+
+// find the user an external identity belongs to. Unknown identities are linked to the account
+// with the same email address, or given a new account, but only when the provider verified the address.
+func (a *apiConfig) oidcUser(r *http.Request, idToken *oidc.IDToken) (database.User, error) {
+	user, err := a.store.GetUserByIdentity(r.Context(), database.GetUserByIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if err == nil {
+		err = a.store.TouchUserIdentity(r.Context(), database.TouchUserIdentityParams{
+			Issuer:  idToken.Issuer,
+			Subject: idToken.Subject,
+			Email:   idToken.Email,
+		})
+		return user, err
+	} else if err != sql.ErrNoRows {
+		return database.User{}, err
+	}
+	if idToken.Email == "" || !idToken.EmailVerified || !validEmail(idToken.Email) {
+		return database.User{}, errUnverifiedIdentity
+	}
+
+	user, err = a.store.GetUserByEmail(r.Context(), idToken.Email)
+	if err == sql.ErrNoRows {
+		user, err = a.createSSOUser(r, idToken.Email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if !user.EmailVerifiedAt.Valid {
+		_, err = a.store.MarkEmailVerified(r.Context(), database.MarkEmailVerifiedParams{
+			ID:    user.ID,
+			Email: user.Email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+	err = a.store.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		UserID:  user.ID,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	log.Printf("Linked identity %v from %v to user %v", idToken.Subject, idToken.Issuer, user.ID)
+	return a.store.GetUserByID(r.Context(), user.ID)
+}
+
+// create an account for someone signing in with the identity provider for the first time.
+// Its password is random and unknown, so it can only be used through single sign-on or after a password reset.
+func (a *apiConfig) createSSOUser(r *http.Request, email string) (database.User, error) {
+	password, err := auth.MakeOpaqueToken()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := a.store.CreateUser(r.Context(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if isUniqueViolation(err) {
+		// signed up through another request in the meantime
+		return a.store.GetUserByEmail(r.Context(), email)
+	}
+	return user, err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/mailer"
+)
+
+const recoveryTokenLifetime = 30 * time.Minute
+
+// email a single-use password recovery link; responds the same whether or not the account exists
+func (a *apiConfig) forgotPasswordHandler(response http.ResponseWriter, r *http.Request) {
+	type forgotPassword struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := forgotPassword{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !a.accountEmailAllowed(response, loginAccountKey(params.Email), "ip:"+clientIP(r)) {
+		return
+	}
+	user, err := a.store.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		noContentResponse(response, "Password recovery requested for unknown email")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	recoveryToken, err := auth.MakeOpaqueToken()
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	recoveryTokenParams := database.CreateRecoveryTokenParams{
+		TokenHash: auth.HashToken(recoveryToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(recoveryTokenLifetime),
+	}
+	err = a.store.CreateRecoveryToken(r.Context(), recoveryTokenParams)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	// send in the background so response times do not reveal whether the account exists
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Reset it here within the next %v:\n%v/reset-password?token=%v\n\n"+
+			"If this was not you, you can ignore this email.\n",
+			recoveryTokenLifetime, a.appURL, recoveryToken),
+	}
+	go a.sendMail(message)
+	noContentResponse(response, "Password recovery email sent")
+}
+
+// set a new password with a recovery token and sign the user out everywhere
+func (a *apiConfig) resetPasswordHandler(response http.ResponseWriter, r *http.Request) {
+	type resetPassword struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := resetPassword{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if params.Password == "" {
+		errorResponse(response, http.StatusBadRequest, "Password is required")
+		return
+	}
+	recoveryToken, err := a.store.GetRecoveryToken(r.Context(), auth.HashToken(params.Token))
+	if err == sql.ErrNoRows {
+		errorResponse(response, http.StatusBadRequest, "Invalid or expired recovery token")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	used, err := a.store.UseRecoveryToken(r.Context(), recoveryToken.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if used == 0 {
+		errorResponse(response, http.StatusBadRequest, "Invalid or expired recovery token")
+		return
+	}
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		HashedPassword: hashedPassword,
+		ID:             recoveryToken.UserID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.InvalidateRecoveryTokens(r.Context(), recoveryToken.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.RevokeAllRefreshTokensForUser(r.Context(), recoveryToken.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.accessTokenDenylist.RevokeIssuedBefore(r.Context(), recoveryToken.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	noContentResponse(response, "Password reset")
+}
+
+// deliver an email, logging failures since nobody is waiting on the result
+func (a *apiConfig) sendMail(message mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := a.mailer.Send(ctx, message)
+	if err != nil {
+		log.Printf("Error sending email %q: %v", message.Subject, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestForgotAndResetPassword(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "old password")
+	session := decodeBody[User](t, api.login(t, "user@example.com", "old password"))
+
+	forgot := map[string]string{"email": "user@example.com"}
+	if response := api.do(t, http.MethodPost, "/api/password/forgot", "", forgot); response.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %v: %s", response.Code, response.Body)
+	}
+	msg := api.waitForMessage(t, "Reset your Chirpy password")
+	if msg.To != "user@example.com" || !strings.Contains(msg.Body, "http://localhost"+port+"/app/reset-password?token=") {
+		t.Errorf("unexpected reset email to %v:\n%v", msg.To, msg.Body)
+	}
+	if response := api.do(t, http.MethodPost, "/api/password/forgot", "", forgot); response.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a second request within a minute to be limited, got %v", response.Code)
+	}
+	api.advance(accountEmailWindow)
+	unknown := map[string]string{"email": "nobody@example.com"}
+	if response := api.do(t, http.MethodPost, "/api/password/forgot", "", unknown); response.Code != http.StatusNoContent {
+		t.Errorf("expected an unknown email to get the same 204, got %v", response.Code)
+	}
+
+	reset := map[string]string{"token": linkToken(t, msg), "password": "new password"}
+	if response := api.do(t, http.MethodPost, "/api/password/reset", "", reset); response.Code != http.StatusNoContent {
+		t.Fatalf("expected the reset to succeed, got %v: %s", response.Code, response.Body)
+	}
+	if response := api.do(t, http.MethodPost, "/api/password/reset", "", reset); response.Code != http.StatusBadRequest {
+		t.Errorf("expected a used recovery token to be rejected, got %v", response.Code)
+	}
+
+	if response := api.login(t, "user@example.com", "old password"); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the old password to stop working, got %v", response.Code)
+	}
+	if response := api.login(t, "user@example.com", "new password"); response.Code != http.StatusOK {
+		t.Errorf("expected the new password to sign in, got %v: %s", response.Code, response.Body)
+	}
+	// the reset signs out every existing session
+	if response := api.do(t, http.MethodPost, "/api/refresh", session.RefreshToken, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected refresh tokens issued before the reset to be revoked, got %v", response.Code)
+	}
+}
+
+func TestForgotPasswordLimitedAccountSpendsNoIPAllowance(t *testing.T) {
+	api := newTestAPI(t)
+	forgot := map[string]string{"email": "user@example.com"}
+	if response := api.do(t, http.MethodPost, "/api/password/forgot", "", forgot); response.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %v: %s", response.Code, response.Body)
+	}
+	// everyone behind a shared address keeps asking for the same account
+	for i := 0; i < ipEmailLimit; i++ {
+		if response := api.do(t, http.MethodPost, "/api/password/forgot", "", forgot); response.Code != http.StatusTooManyRequests {
+			t.Fatalf("repeat %v: expected 429, got %v", i+1, response.Code)
+		}
+	}
+	other := map[string]string{"email": "other@example.com"}
+	if response := api.do(t, http.MethodPost, "/api/password/forgot", "", other); response.Code != http.StatusNoContent {
+		t.Errorf("expected requests refused by the account limit not to use up the address's allowance, got %v", response.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+// a signed in device: one refresh token family
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+}
+
+// list the devices the user is signed in on
+func (a *apiConfig) listSessionsHandler(response http.ResponseWriter, r *http.Request) {
+	principal := currentPrincipal(r)
+	rows, err := a.store.ListSessions(r.Context(), principal.UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	sessions := []Session{}
+	for _, row := range rows {
+		sessions = append(sessions, jsonSafeSession(row, principal.SessionID))
+	}
+	jsonResponse(response, http.StatusOK, sessions, "Sessions listed")
+}
+
+// sign out a single device, cutting off its access token too
+func (a *apiConfig) revokeSessionHandler(response http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+	principal := currentPrincipal(r)
+	revoked, err := a.store.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   principal.UserID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if revoked == 0 {
+		errorResponse(response, http.StatusNotFound, "Session not found")
+		return
+	}
+	if sessionID == principal.SessionID {
+		// signing out the current device leaves nothing to hand a new token to
+		principal = &auth.Principal{UserID: principal.UserID}
+		clearSessionCookies(response)
+	}
+	a.sessionsRevoked(response, r, principal, "Session revoked")
+}
+
+// sign out every device except the one making the request, cutting off their access tokens too
+func (a *apiConfig) revokeAllSessionsHandler(response http.ResponseWriter, r *http.Request) {
+	principal := currentPrincipal(r)
+	// without a current session every session goes
+	err := a.store.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   principal.UserID,
+		FamilyID: principal.SessionID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	a.sessionsRevoked(response, r, principal, "Other sessions revoked")
+}
+
+// end the access tokens of signed out devices now rather than when they expire. Every
+// access token the user holds is revoked, devices still signed in get new ones when they
+// refresh, and the caller is handed a replacement for its own.
+func (a *apiConfig) sessionsRevoked(response http.ResponseWriter, r *http.Request, principal *auth.Principal, mesg string) {
+	accessToken, err := a.revokeAccessTokens(response, r, principal, principal.Role, principal.AuthTime)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if accessToken == "" {
+		noContentResponse(response, mesg)
+		return
+	}
+	jsonResponse(response, http.StatusOK, token{Token: accessToken}, mesg)
+}
+
+// revoke every access token issued to the user so far and mint the current session a
+// replacement, set as a cookie when the request came with one and returned otherwise.
+// Delegated credentials have no session to keep signed in and get nothing.
+func (a *apiConfig) revokeAccessTokens(response http.ResponseWriter, r *http.Request, principal *auth.Principal, role string, authTime time.Time) (string, error) {
+	err := a.accessTokenDenylist.RevokeIssuedBefore(r.Context(), principal.UserID)
+	if err != nil {
+		return "", err
+	}
+	if principal.SessionID == uuid.Nil || principal.Scopes != nil {
+		return "", nil
+	}
+	options := []auth.TokenOption{auth.WithSessionID(principal.SessionID), auth.WithRole(role)}
+	if !authTime.IsZero() {
+		options = append(options, auth.WithAuthTime(authTime))
+	}
+	accessToken, err := auth.MakeJWT(principal.UserID, accessTokenLifetime, options...)
+	if err != nil {
+		return "", err
+	}
+	if _, fromCookie, _ := auth.GetAccessToken(r); fromCookie {
+		a.setAccessTokenCookie(response, accessToken)
+		return "", nil
+	}
+	return accessToken, nil
+}
+
+// sign out of the current session and revoke the access token used to do it
+func (a *apiConfig) logoutHandler(response http.ResponseWriter, r *http.Request) {
+	principal := currentPrincipal(r)
+	err := a.accessTokenDenylist.Deny(r.Context(), principal)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if principal.SessionID != uuid.Nil {
+		_, err = a.store.RevokeSession(r.Context(), database.RevokeSessionParams{
+			FamilyID: principal.SessionID,
+			UserID:   principal.UserID,
+		})
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+	}
+	clearSessionCookies(response)
+	noContentResponse(response, "Logged out")
+}
+
+// Parse a ListSessions row into the json Session struct
+func jsonSafeSession(row database.ListSessionsRow, currentSessionID uuid.UUID) Session {
+	return Session{
+		ID:         row.FamilyID,
+		CreatedAt:  row.CreatedAt,
+		LastUsedAt: row.LastUsedAt,
+		ExpiresAt:  row.ExpiresAt,
+		UserAgent:  row.UserAgent,
+		IPAddress:  row.IpAddress,
+		Current:    row.FamilyID == currentSessionID,
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRevokeSessions(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+	current := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	phone := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+	laptop := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+
+	listSessions := func(accessToken string) []Session {
+		t.Helper()
+		response := api.do(t, http.MethodGet, "/api/sessions", accessToken, nil)
+		if response.Code != http.StatusOK {
+			t.Fatalf("listing sessions failed: %v: %s", response.Code, response.Body)
+		}
+		return decodeBody[[]Session](t, response)
+	}
+	currentSession := func(sessions []Session) Session {
+		t.Helper()
+		for _, session := range sessions {
+			if session.Current {
+				return session
+			}
+		}
+		t.Fatalf("no current session in %+v", sessions)
+		return Session{}
+	}
+	sessions := listSessions(current.Token)
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %+v", sessions)
+	}
+	phoneSession := currentSession(listSessions(phone.Token))
+
+	// access tokens are cut off by issue time, which has one second resolution
+	time.Sleep(time.Second)
+	response := api.do(t, http.MethodDelete, "/api/sessions/"+phoneSession.ID.String(), current.Token, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("revoking the phone's session failed: %v: %s", response.Code, response.Body)
+	}
+	reissued := decodeBody[token](t, response).Token
+	if response := api.do(t, http.MethodGet, "/api/sessions", phone.Token, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the signed out phone's access token to stop working, got %v", response.Code)
+	}
+	if response := api.do(t, http.MethodPost, "/api/refresh", phone.RefreshToken, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the signed out phone not to refresh, got %v", response.Code)
+	}
+	if response := api.do(t, http.MethodGet, "/api/sessions", laptop.Token, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected access tokens issued before the revocation to stop working, got %v", response.Code)
+	}
+	response = api.do(t, http.MethodPost, "/api/refresh", laptop.RefreshToken, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected a device still signed in to get a new access token, got %v: %s", response.Code, response.Body)
+	}
+	laptopRefresh := decodeBody[token](t, response).RefreshToken
+	if sessions := listSessions(reissued); len(sessions) != 2 || currentSession(sessions).ID == phoneSession.ID {
+		t.Errorf("expected the caller's replacement token to keep its session, got %+v", sessions)
+	}
+	if response := api.do(t, http.MethodDelete, "/api/sessions/"+phoneSession.ID.String(), reissued, nil); response.Code != http.StatusNotFound {
+		t.Errorf("expected a revoked session not to be found again, got %v", response.Code)
+	}
+
+	response = api.do(t, http.MethodPost, "/api/sessions/revoke-all", reissued, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("revoking other sessions failed: %v: %s", response.Code, response.Body)
+	}
+	reissued = decodeBody[token](t, response).Token
+	if response := api.do(t, http.MethodPost, "/api/refresh", laptopRefresh, nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("expected the laptop to be signed out, got %v", response.Code)
+	}
+	if sessions := listSessions(reissued); len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("expected only the current session to remain, got %+v", sessions)
+	}
+}
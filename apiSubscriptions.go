@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// billing period assumed when Polka does not say when the paid period ends
+const defaultSubscriptionPeriod = 30 * 24 * time.Hour
+
+// the data Polka sends with each billing event
+type polkaEventData struct {
+	UserID    string     `json:"user_id"`
+	Plan      string     `json:"plan"`
+	PeriodEnd *time.Time `json:"period_end"`
+}
+
+type subscriptionEventHandler func(a *apiConfig, ctx context.Context, userID uuid.UUID, data polkaEventData) error
+
+// Polka events that change a subscription; any other event is acknowledged and ignored
+var subscriptionEvents = map[string]subscriptionEventHandler{
+	"user.upgraded":        (*apiConfig).startSubscription,
+	"subscription.renewed": (*apiConfig).renewSubscription,
+	"user.downgraded":      (*apiConfig).cancelSubscription,
+	"payment.failed":       (*apiConfig).subscriptionPaymentFailed,
+	"payment.refunded":     (*apiConfig).refundSubscription,
+}
+
+var errUnknownSubscriber = errors.New("subscription event for unknown user")
+
+type Subscription struct {
+	Plan               string     `json:"plan"`
+	Status             string     `json:"status"`
+	CurrentPeriodStart *time.Time `json:"current_period_start"`
+	CurrentPeriodEnd   *time.Time `json:"current_period_end"`
+	CancelAtPeriodEnd  bool       `json:"cancel_at_period_end"`
+	CanceledAt         *time.Time `json:"canceled_at"`
+	IsChirpyRed        bool       `json:"is_chirpy_red"`
+}
+
+// show the user's Chirpy Red subscription
+func (a *apiConfig) getSubscriptionHandler(response http.ResponseWriter, r *http.Request) {
+	user, err := a.store.GetUserByID(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	subscription, err := a.store.GetSubscription(r.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		if !user.IsChirpyRed {
+			errorResponse(response, http.StatusNotFound, "No subscription")
+			return
+		}
+		// upgraded before subscriptions were tracked, so there is no billing period to show
+		jsonResponse(response, http.StatusOK, Subscription{Plan: entitlements.PlanChirpyRed, Status: "active", IsChirpyRed: true}, "Subscription found")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusOK, jsonSafeSubscription(subscription, user.IsChirpyRed), "Subscription found")
+}
+
+// start or restart a subscription with a new paid period
+func (a *apiConfig) startSubscription(ctx context.Context, userID uuid.UUID, data polkaEventData) error {
+	plan := data.Plan
+	if plan == "" {
+		plan = entitlements.PlanChirpyRed
+	}
+	err := a.activateSubscription(ctx, userID, plan, time.Now(), data.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	type upgradedUser struct {
+		UserID uuid.UUID `json:"user_id"`
+		Plan   string    `json:"plan"`
+	}
+	a.publishEvent(ctx, eventUserUpgraded, userID, upgradedUser{UserID: userID, Plan: plan})
+	return nil
+}
+
+// extend a subscription by another paid period, following on from the current one when it has not run out
+func (a *apiConfig) renewSubscription(ctx context.Context, userID uuid.UUID, data polkaEventData) error {
+	start, plan := time.Now(), data.Plan
+	existing, err := a.store.GetSubscription(ctx, userID)
+	if err == nil {
+		if existing.CurrentPeriodEnd.After(start) && (existing.Status == "active" || existing.Status == "past_due") {
+			start = existing.CurrentPeriodEnd
+		}
+		if plan == "" {
+			plan = existing.Plan
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	if plan == "" {
+		plan = entitlements.PlanChirpyRed
+	}
+	return a.activateSubscription(ctx, userID, plan, start, data.PeriodEnd)
+}
+
+func (a *apiConfig) activateSubscription(ctx context.Context, userID uuid.UUID, plan string, start time.Time, periodEnd *time.Time) error {
+	end := start.Add(defaultSubscriptionPeriod)
+	if periodEnd != nil && periodEnd.After(start) {
+		end = *periodEnd
+	}
+	_, err := a.store.StartSubscription(ctx, database.StartSubscriptionParams{
+		UserID:             userID,
+		Plan:               plan,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+	})
+	if isForeignKeyViolation(err) {
+		return errUnknownSubscriber
+	} else if err != nil {
+		return err
+	}
+	log.Printf("Chirpy Red active for user %v until %v", userID, end)
+	return a.store.ActivateChirpyRed(ctx, userID)
+}
+
+// a downgrade keeps Chirpy Red until the end of the period already paid for
+func (a *apiConfig) cancelSubscription(ctx context.Context, userID uuid.UUID, data polkaEventData) error {
+	cancelled, err := a.store.CancelSubscription(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if cancelled == 0 {
+		// nothing tracked to run out, as for users upgraded before subscriptions were
+		return a.store.DeactivateChirpyRed(ctx, userID)
+	}
+	log.Printf("Chirpy Red for user %v cancelled at the end of the period", userID)
+	return nil
+}
+
+// Polka retries failed payments, so Chirpy Red stays until the period ends and the subscription expires
+func (a *apiConfig) subscriptionPaymentFailed(ctx context.Context, userID uuid.UUID, data polkaEventData) error {
+	_, err := a.store.MarkSubscriptionPastDue(ctx, userID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Payment failed for the Chirpy Red subscription of user %v", userID)
+	return nil
+}
+
+// a refunded period was never paid for, so Chirpy Red ends immediately
+func (a *apiConfig) refundSubscription(ctx context.Context, userID uuid.UUID, data polkaEventData) error {
+	_, err := a.store.RefundSubscription(ctx, userID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Chirpy Red for user %v ended by a refund", userID)
+	return a.store.DeactivateChirpyRed(ctx, userID)
+}
+
+// end Chirpy Red for subscriptions whose paid period is over
+func (a *apiConfig) expireSubscriptions(ctx context.Context) error {
+	expired, err := a.store.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range expired {
+		log.Printf("Chirpy Red subscription of user %v expired", userID)
+	}
+	return nil
+}
+
+// Parse generated Subscription struct into local json Subscription struct
+func jsonSafeSubscription(subscription database.Subscription, isChirpyRed bool) Subscription {
+	jsonSubscription := Subscription{
+		Plan:               subscription.Plan,
+		Status:             subscription.Status,
+		CurrentPeriodStart: &subscription.CurrentPeriodStart,
+		CurrentPeriodEnd:   &subscription.CurrentPeriodEnd,
+		CancelAtPeriodEnd:  subscription.CancelAtPeriodEnd,
+		IsChirpyRed:        isChirpyRed,
+	}
+	if subscription.CanceledAt.Valid {
+		jsonSubscription.CanceledAt = &subscription.CanceledAt.Time
+	}
+	return jsonSubscription
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxTokenNameLength = 100
+
+// a named, scoped credential for scripts and bots
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+type newToken struct {
+	Name             string   `json:"name"`
+	Scopes           []string `json:"scopes"`
+	ExpiresInSeconds int64    `json:"expires_in_seconds"`
+}
+
+// mint a personal access token; the raw token is only ever returned here
+func (a *apiConfig) createTokenHandler(response http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	params := newToken{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > maxTokenNameLength {
+		errorResponse(response, http.StatusBadRequest, "Token name is required and must be at most 100 characters")
+		return
+	}
+	if len(params.Scopes) == 0 {
+		errorResponse(response, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			errorResponse(response, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+	if params.ExpiresInSeconds < 0 {
+		errorResponse(response, http.StatusBadRequest, "expires_in_seconds must not be negative")
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	rawToken, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	pat, err := a.store.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    currentPrincipal(r).UserID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(rawToken),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	created := jsonSafeToken(pat)
+	created.Token = rawToken
+	jsonResponse(response, http.StatusCreated, created, "Personal access token created")
+}
+
+// list the user's active personal access tokens
+func (a *apiConfig) listTokensHandler(response http.ResponseWriter, r *http.Request) {
+	rows, err := a.store.ListPersonalAccessTokens(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	tokens := []PersonalAccessToken{}
+	for _, row := range rows {
+		tokens = append(tokens, jsonSafeToken(row))
+	}
+	jsonResponse(response, http.StatusOK, tokens, "Personal access tokens listed")
+}
+
+// revoke one of the user's personal access tokens
+func (a *apiConfig) revokeTokenHandler(response http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+	revoked, err := a.store.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: currentPrincipal(r).UserID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if revoked == 0 {
+		errorResponse(response, http.StatusNotFound, "Token not found")
+		return
+	}
+	noContentResponse(response, "Personal access token revoked")
+}
+
+func jsonSafeToken(row database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		ID:        row.ID,
+		Name:      row.Name,
+		Scopes:    row.Scopes,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		token.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		token.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return token
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+const twoFactorChallengeLifetime = 5 * time.Minute
+const recoveryCodeCount = 10
+
+type twoFactorCode struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// tell the client a second factor is needed to finish logging in
+func (a *apiConfig) twoFactorChallenge(response http.ResponseWriter, user database.User) {
+	type challenge struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+
+	challengeToken, err := auth.MakeTwoFactorChallenge(user.ID, twoFactorChallengeLifetime)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusAccepted, challenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    challengeToken,
+	}, "Two-factor challenge issued")
+}
+
+// exchange a challenge token and a TOTP or recovery code for the usual token pair
+func (a *apiConfig) twoFactorLoginHandler(response http.ResponseWriter, r *http.Request) {
+	type twoFactorLogin struct {
+		ChallengeToken string `json:"challenge_token"`
+		twoFactorCode
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := twoFactorLogin{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	userID, err := auth.ValidateTwoFactorChallenge(params.ChallengeToken)
+	if err != nil {
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid or expired challenge token")
+		return
+	}
+	user, err := a.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	accountKey, ipKey := loginAccountKey(user.Email), "ip:"+clientIP(r)
+	if !a.loginAllowed(response, accountKey, ipKey) {
+		return
+	}
+	ok, err := a.checkSecondFactor(r, user, params.twoFactorCode)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if !ok {
+		a.loginAccountThrottle.Failure(accountKey)
+		a.loginIPThrottle.Failure(ipKey)
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid two-factor code")
+		return
+	}
+	a.completeLogin(response, r, user)
+}
+
+// start TOTP enrollment by generating a secret for the user's authenticator app
+func (a *apiConfig) twoFactorSetupHandler(response http.ResponseWriter, r *http.Request) {
+	type setup struct {
+		Secret     string `json:"secret"`
+		OTPAuthURL string `json:"otpauth_url"`
+	}
+
+	user, ok := a.twoFactorUser(response, r)
+	if !ok {
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		errorResponse(response, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		ID:         user.ID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusOK, setup{
+		Secret:     secret,
+		OTPAuthURL: auth.TOTPURI(secret, "Chirpy", user.Email),
+	}, "Two-factor setup started")
+}
+
+// finish TOTP enrollment with a code from the app and hand out recovery codes
+func (a *apiConfig) twoFactorConfirmHandler(response http.ResponseWriter, r *http.Request) {
+	type confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	user, ok := a.twoFactorUser(response, r)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := twoFactorCode{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		errorResponse(response, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		errorResponse(response, http.StatusBadRequest, "Two-factor setup has not been started")
+		return
+	}
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, params.Code, time.Now())
+	if !ok {
+		errorResponse(response, http.StatusBadRequest, "Invalid two-factor code")
+		return
+	}
+	err = a.store.EnableTOTP(r.Context(), database.EnableTOTPParams{
+		TotpLastStep: step,
+		ID:           user.ID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	codes, err := a.replaceRecoveryCodes(r, user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusOK, confirmed{RecoveryCodes: codes}, "Two-factor authentication enabled")
+}
+
+// turn TOTP off after checking a current code or a recovery code
+func (a *apiConfig) twoFactorDisableHandler(response http.ResponseWriter, r *http.Request) {
+	user, ok := a.twoFactorUser(response, r)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := twoFactorCode{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		errorResponse(response, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	// guessing codes here is as good as guessing them at sign in, so it counts against the same throttles
+	accountKey, ipKey := loginAccountKey(user.Email), "ip:"+clientIP(r)
+	if !a.loginAllowed(response, accountKey, ipKey) {
+		return
+	}
+	ok, err = a.checkSecondFactor(r, user, params)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if !ok {
+		a.loginAccountThrottle.Failure(accountKey)
+		a.loginIPThrottle.Failure(ipKey)
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid two-factor code")
+		return
+	}
+	a.loginAccountThrottle.Success(accountKey)
+	err = a.store.DisableTOTP(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.store.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	noContentResponse(response, "Two-factor authentication disabled")
+}
+
+// load the authenticated user making the request
+func (a *apiConfig) twoFactorUser(response http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := a.store.GetUserByID(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// check a TOTP code, refusing one whose time step was already used, or spend a recovery code
+func (a *apiConfig) checkSecondFactor(r *http.Request, user database.User, params twoFactorCode) (bool, error) {
+	if params.RecoveryCode != "" {
+		used, err := a.store.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(params.RecoveryCode)),
+		})
+		return used == 1, err
+	}
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, params.Code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	recorded, err := a.store.RecordTOTPStep(r.Context(), database.RecordTOTPStepParams{
+		TotpLastStep: step,
+		ID:           user.ID,
+	})
+	return recorded == 1, err
+}
+
+// generate a fresh set of recovery codes, invalidating any earlier ones
+func (a *apiConfig) replaceRecoveryCodes(r *http.Request, userID uuid.UUID) ([]string, error) {
+	codes, err := auth.MakeRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	err = a.store.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err = a.store.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
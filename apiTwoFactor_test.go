@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+)
+
+func TestTwoFactorDisableIsThrottled(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+	user := decodeBody[User](t, api.login(t, "user@example.com", "correct horse"))
+
+	response := api.do(t, http.MethodPost, "/api/2fa/setup", user.Token, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("starting setup failed: %v: %s", response.Code, response.Body)
+	}
+	secret := decodeBody[map[string]string](t, response)["secret"]
+	code, err := auth.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	response = api.do(t, http.MethodPost, "/api/2fa/confirm", user.Token, twoFactorCode{Code: code})
+	if response.Code != http.StatusOK {
+		t.Fatalf("confirming setup failed: %v: %s", response.Code, response.Body)
+	}
+	recoveryCodes := decodeBody[map[string][]string](t, response)["recovery_codes"]
+
+	// waiting out each delay, so every guess is checked and counted
+	for i := 0; i < accountLockoutThreshold; i++ {
+		response := api.do(t, http.MethodPost, "/api/2fa/disable", user.Token, twoFactorCode{RecoveryCode: "wrong"})
+		if response.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %v: expected 401, got %v: %s", i+1, response.Code, response.Body)
+		}
+		api.advance(time.Minute)
+	}
+	response = api.do(t, http.MethodPost, "/api/2fa/disable", user.Token, twoFactorCode{RecoveryCode: recoveryCodes[0]})
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected guessing to lock the account out even for a good code, got %v", response.Code)
+	}
+	if response := api.login(t, "user@example.com", "correct horse"); response.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the lockout to cover sign in too, got %v", response.Code)
+	}
+
+	api.advance(loginLockoutDuration)
+	response = api.do(t, http.MethodPost, "/api/2fa/disable", user.Token, twoFactorCode{RecoveryCode: recoveryCodes[0]})
+	if response.Code != http.StatusNoContent {
+		t.Errorf("expected a recovery code to disable two-factor once the lockout ended, got %v: %s", response.Code, response.Body)
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -13,13 +13,17 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email,omitempty"`
+	Role          string    `json:"role"`
+	CSRFToken     string    `json:"csrf_token,omitempty"`
 }
 
 type handleUser struct {
@@ -37,6 +41,10 @@ func (a *apiConfig) createUserHandler(response http.ResponseWriter, r *http.Requ
 		internalError(response, err)
 		return
 	}
+	if !validEmail(params.Email) {
+		errorResponse(response, http.StatusBadRequest, "Invalid email address")
+		return
+	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		internalError(response, err)
@@ -46,7 +54,15 @@ func (a *apiConfig) createUserHandler(response http.ResponseWriter, r *http.Requ
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
 	}
-	newUser, err := a.databaseQueries.CreateUser(r.Context(), compatibleParams)
+	newUser, err := a.store.CreateUser(r.Context(), compatibleParams)
+	if isUniqueViolation(err) {
+		errorResponse(response, http.StatusConflict, "Email address already in use")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	err = a.sendVerificationEmail(newUser.ID, newUser.Email)
 	if err != nil {
 		internalError(response, err)
 		return
@@ -66,117 +82,231 @@ func (a *apiConfig) loginHandler(response http.ResponseWriter, r *http.Request)
 		internalError(response, err)
 		return
 	}
-	user, err := a.databaseQueries.GetUserByEmail(r.Context(), params.Email)
-	if err != nil {
-		internalError(response, err)
+	accountKey, ipKey := loginAccountKey(params.Email), "ip:"+clientIP(r)
+	if !a.loginAllowed(response, accountKey, ipKey) {
 		return
 	}
-	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
-	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Incorrect Password")
+	user, err := a.store.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		auth.CheckDummyPassword(params.Password)
+		a.loginFailed(response, accountKey, ipKey)
 		return
-	}
-	loggedInUser := jsonReturnUser(user)
-	token, err := auth.MakeJWT(loggedInUser.ID, 3600*time.Second)
-	if err != nil {
+	} else if err != nil {
 		internalError(response, err)
 		return
 	}
-	loggedInUser.Token = token
-	loggedInUser.RefreshToken, err = auth.MakeRefreshToken()
+	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
 	if err != nil {
-		internalError(response, err)
+		a.loginFailed(response, accountKey, ipKey)
 		return
 	}
-	refreshTokenParams := database.CreateRefreshTokenParams{
-		Token:     loggedInUser.RefreshToken,
-		UserID:    loggedInUser.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
+	// the address keeps its failures until they age out, so signing in to an account
+	// of one's own does not reset the count while guessing at others
+	a.loginAccountThrottle.Success(accountKey)
+	if auth.PasswordNeedsRehash(user.HashedPassword) {
+		a.rehashPassword(r, user, params.Password)
 	}
-	err = a.databaseQueries.CreateRefreshToken(r.Context(), refreshTokenParams)
-	if err != nil {
-		internalError(response, err)
+	a.finishLogin(response, r, user)
+}
+
+// apply the checks every sign in method shares once the user has proven who they are
+func (a *apiConfig) finishLogin(response http.ResponseWriter, r *http.Request, user database.User) {
+	if a.requiresVerifiedEmail(verifiedEmailForLogin) && !user.EmailVerifiedAt.Valid {
+		errorResponse(response, http.StatusForbidden, "Forbidden: Verify your email address before logging in")
 		return
 	}
-
-	jsonResponse(response, http.StatusOK, loggedInUser, "User logged in successfully")
+	if user.TotpEnabledAt.Valid {
+		a.twoFactorChallenge(response, user)
+		return
+	}
+	a.completeLogin(response, r, user)
 }
 
-// update user password and email
-func (a *apiConfig) updateAccount(response http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	newData := handleUser{}
-	err := decoder.Decode(&newData)
+// upgrade a stored password hash to the current algorithm and parameters; failures only cost the upgrade
+func (a *apiConfig) rehashPassword(r *http.Request, user database.User, password string) {
+	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		internalError(response, err)
+		log.Printf("Error rehashing password for user %v: %v", user.ID, err)
 		return
 	}
-	userToken, err := auth.GetBearerToken(r.Header)
+	err = a.store.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		HashedPassword: hashedPassword,
+		ID:             user.ID,
+	})
 	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid access token")
+		log.Printf("Error storing rehashed password for user %v: %v", user.ID, err)
 		return
 	}
-	userID, err := auth.ValidateJWT(userToken)
+	log.Printf("Password hash upgraded for user %v", user.ID)
+}
+
+// issue an access and refresh token pair to a user who has proven who they are
+func (a *apiConfig) completeLogin(response http.ResponseWriter, r *http.Request, user database.User) {
+	err := a.cancelAccountDeletion(r, user)
 	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid access token")
+		internalError(response, err)
 		return
 	}
-	user, err := a.databaseQueries.GetUserByID(r.Context(), userID)
+	loggedInUser := jsonReturnUser(user)
+	sessionID := uuid.New()
+	token, err := auth.MakeJWT(loggedInUser.ID, accessTokenLifetime,
+		auth.WithSessionID(sessionID), auth.WithRole(user.Role), auth.WithAuthTime(time.Now()))
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	user.Email = newData.Email
-	user.HashedPassword, err = auth.HashPassword(newData.Password)
+	loggedInUser.Token = token
+	loggedInUser.RefreshToken, err = a.issueRefreshToken(r, loggedInUser.ID, sessionID)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	jsonData := jsonReturnUser(user)
-	jsonResponse(response, http.StatusOK, jsonData, "Account successfully updated")
+	if cookieSessionRequested(r) {
+		// the tokens never reach script-readable storage
+		a.setSessionCookies(response, loggedInUser.Token, loggedInUser.RefreshToken, sessionID)
+		loggedInUser.Token, loggedInUser.RefreshToken = "", ""
+		loggedInUser.CSRFToken = auth.CSRFToken(sessionID)
+	}
 
+	jsonResponse(response, http.StatusOK, loggedInUser, "User logged in successfully")
 }
 
-func (a *apiConfig) upgradeAccount(response http.ResponseWriter, r *http.Request) {
-	type Upgrade struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+// how long after signing in a user may change their credentials without re-entering their password
+const reauthWindow = 5 * time.Minute
+
+// partially update the user's email and/or password. Credential changes need the current password
+// unless the user signed in within reauthWindow, and a new password signs out every other session.
+func (a *apiConfig) updateAccount(response http.ResponseWriter, r *http.Request) {
+	type updateAccount struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
 	}
 
-	incKey, err := auth.GetAPIKey(r.Header)
+	decoder := json.NewDecoder(r.Body)
+	params := updateAccount{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		errorResponse(response, http.StatusUnauthorized, fmt.Sprintf("Unauthorized: %v", err.Error()))
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-	if incKey != a.polkaKey {
-		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid API key")
+	if params.Email == nil && params.Password == nil {
+		errorResponse(response, http.StatusBadRequest, "Nothing to update")
 		return
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	upgradeData := Upgrade{}
-	err = decoder.Decode(&upgradeData)
+	principal := currentPrincipal(r)
+	user, err := a.store.GetUserByID(r.Context(), principal.UserID)
 	if err != nil {
 		internalError(response, err)
 		return
 	}
-	if upgradeData.Event != "user.upgraded" {
-		noContentResponse(response, "Event not supported")
+	if !a.confirmCredentialChange(response, r, principal, user, params.CurrentPassword) {
 		return
 	}
-	userID, err := uuid.Parse(upgradeData.Data.UserID)
-	if err != nil {
-		internalError(response, err)
-		return
+
+	update := database.UpdateAccountParams{ID: user.ID}
+	if params.Email != nil && *params.Email != user.Email {
+		// the new address only replaces the current one once it has been verified
+		if !validEmail(*params.Email) {
+			errorResponse(response, http.StatusBadRequest, "Invalid email address")
+			return
+		}
+		_, err = a.store.GetUserByEmail(r.Context(), *params.Email)
+		if err == nil {
+			errorResponse(response, http.StatusConflict, "Email address already in use")
+			return
+		} else if err != sql.ErrNoRows {
+			internalError(response, err)
+			return
+		}
+		update.PendingEmail = sql.NullString{String: *params.Email, Valid: true}
 	}
-	err = a.databaseQueries.ActivateChirpyRed(r.Context(), userID)
-	if err == sql.ErrNoRows {
-		errorResponse(response, http.StatusNotFound, "User not found")
+	if params.Password != nil {
+		if *params.Password == "" {
+			errorResponse(response, http.StatusBadRequest, "Password is required")
+			return
+		}
+		hashedPassword, err := auth.HashPassword(*params.Password)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+		update.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+	user, err = a.store.UpdateAccount(r.Context(), update)
+	if isUniqueViolation(err) {
+		errorResponse(response, http.StatusConflict, "Email address already in use")
+		return
 	} else if err != nil {
-		noContentResponse(response, err.Error())
+		internalError(response, err)
 		return
 	}
-	noContentResponse(response, "User successfully upgraded")
+	if update.PendingEmail.Valid {
+		err = a.sendVerificationEmail(user.ID, update.PendingEmail.String)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+	}
+	updatedUser := jsonReturnUser(user)
+	if update.HashedPassword.Valid {
+		authTime := principal.AuthTime
+		if params.CurrentPassword != "" {
+			authTime = time.Now()
+		}
+		err = a.passwordChanged(response, r, principal, authTime, &updatedUser)
+		if err != nil {
+			internalError(response, err)
+			return
+		}
+	}
+	jsonResponse(response, http.StatusOK, updatedUser, "Account successfully updated")
+}
+
+// require the current password for a credential change, unless the caller signed in recently.
+// Wrong passwords count towards the login lockout so this cannot be used to guess them.
+func (a *apiConfig) confirmCredentialChange(response http.ResponseWriter, r *http.Request, principal *auth.Principal, user database.User, currentPassword string) bool {
+	if currentPassword == "" {
+		if !principal.AuthTime.IsZero() && time.Since(principal.AuthTime) < reauthWindow {
+			return true
+		}
+		errorResponse(response, http.StatusForbidden, "Forbidden: Confirm your current password to change your credentials")
+		return false
+	}
+	accountKey, ipKey := loginAccountKey(user.Email), "ip:"+clientIP(r)
+	if !a.loginAllowed(response, accountKey, ipKey) {
+		return false
+	}
+	err := auth.CheckPasswordHash(user.HashedPassword, currentPassword)
+	if err != nil {
+		a.loginAccountThrottle.Failure(accountKey)
+		a.loginIPThrottle.Failure(ipKey)
+		errorResponse(response, http.StatusForbidden, "Forbidden: Incorrect current password")
+		return false
+	}
+	a.loginAccountThrottle.Success(accountKey)
+	return true
+}
+
+// sign out everywhere but the current session after a password change, handing the
+// current session a fresh access token since every earlier one has been revoked
+func (a *apiConfig) passwordChanged(response http.ResponseWriter, r *http.Request, principal *auth.Principal, authTime time.Time, updatedUser *User) error {
+	// without a current session every session goes
+	err := a.store.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   principal.UserID,
+		FamilyID: principal.SessionID,
+	})
+	if err != nil {
+		return err
+	}
+	err = a.store.InvalidateRecoveryTokens(r.Context(), principal.UserID)
+	if err != nil {
+		return err
+	}
+	err = a.store.InvalidateMagicLinks(r.Context(), principal.UserID)
+	if err != nil {
+		return err
+	}
+	updatedUser.Token, err = a.revokeAccessTokens(response, r, principal, updatedUser.Role, authTime)
+	return err
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+
+	unknown := api.login(t, "nobody@example.com", "correct horse")
+	wrongPassword := api.login(t, "user@example.com", "battery staple")
+	if unknown.Code != http.StatusUnauthorized || wrongPassword.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for both, got %v and %v", unknown.Code, wrongPassword.Code)
+	}
+	if unknown.Body.String() != wrongPassword.Body.String() {
+		t.Errorf("expected an unknown email and a wrong password to get the same response, got %q and %q",
+			unknown.Body, wrongPassword.Body)
+	}
+
+	if response := api.login(t, "user@example.com", "correct horse"); response.Code != http.StatusOK {
+		t.Errorf("expected the right password to sign in, got %v: %s", response.Code, response.Body)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "user@example.com", "correct horse")
+
+	// waiting out each delay, so every attempt is checked and counted
+	for i := 0; i < accountLockoutThreshold; i++ {
+		if response := api.login(t, "user@example.com", "wrong"); response.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %v: expected 401, got %v: %s", i+1, response.Code, response.Body)
+		}
+		api.advance(time.Minute)
+	}
+
+	response := api.login(t, "user@example.com", "correct horse")
+	if response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a locked out account to get 429 even with the right password, got %v", response.Code)
+	}
+	// locked out for loginLockoutDuration from the last failure, a minute ago
+	if retryAfter := response.Header().Get("Retry-After"); retryAfter != "840" {
+		t.Errorf("expected Retry-After 840, got %q", retryAfter)
+	}
+	if other := api.login(t, "other@example.com", "wrong"); other.Code != http.StatusUnauthorized {
+		t.Errorf("expected other accounts not to be locked out, got %v", other.Code)
+	}
+
+	api.advance(loginLockoutDuration)
+	if response := api.login(t, "user@example.com", "correct horse"); response.Code != http.StatusOK {
+		t.Errorf("expected to sign in once the lockout ended, got %v: %s", response.Code, response.Body)
+	}
+}
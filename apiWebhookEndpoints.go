@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// events that can be sent to registered webhook endpoints
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserUpgraded = "user.upgraded"
+)
+
+var outboundEvents = map[string]bool{
+	eventChirpCreated: true,
+	eventChirpDeleted: true,
+	eventUserUpgraded: true,
+}
+
+// a delivery still failing after this many attempts is dead-lettered
+const maxWebhookDeliveryAttempts = 8
+const webhookDeliveryBatchSize = 20
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	// only returned when the endpoint is registered
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode int32           `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// the body of every delivery
+type outboundEvent struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// register a URL to be sent the chosen events; the signing secret is only shown in this response
+func (a *apiConfig) createWebhookEndpointHandler(response http.ResponseWriter, r *http.Request) {
+	type createWebhookEndpoint struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := createWebhookEndpoint{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	principal := currentPrincipal(r)
+	if !validWebhookURL(params.URL, principal.HasRole(auth.RoleAdmin)) {
+		errorResponse(response, http.StatusBadRequest, "Invalid url, webhooks are delivered over https")
+		return
+	}
+	if !publicWebhookHost(r.Context(), params.URL) {
+		errorResponse(response, http.StatusBadRequest, "Invalid url, webhooks are only delivered to public addresses")
+		return
+	}
+	if len(params.Events) == 0 {
+		errorResponse(response, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+	events := []string{}
+	seen := map[string]bool{}
+	for _, event := range params.Events {
+		if !outboundEvents[event] {
+			errorResponse(response, http.StatusBadRequest, "Unknown event: "+truncate(event, 64))
+			return
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	secret, err := auth.MakeOpaqueToken()
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	endpoint, err := a.store.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: principal.UserID,
+		Url:    params.URL,
+		Secret: "whsec_" + secret,
+		Events: events,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonEndpoint := jsonSafeWebhookEndpoint(endpoint)
+	jsonEndpoint.Secret = endpoint.Secret
+	jsonResponse(response, http.StatusCreated, jsonEndpoint, "Webhook endpoint registered")
+}
+
+// list the user's webhook endpoints
+func (a *apiConfig) listWebhookEndpointsHandler(response http.ResponseWriter, r *http.Request) {
+	endpoints, err := a.store.ListWebhookEndpoints(r.Context(), currentPrincipal(r).UserID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonEndpoints := []WebhookEndpoint{}
+	for _, endpoint := range endpoints {
+		jsonEndpoints = append(jsonEndpoints, jsonSafeWebhookEndpoint(endpoint))
+	}
+	jsonResponse(response, http.StatusOK, jsonEndpoints, "Webhook endpoints listed")
+}
+
+// stop sending events to an endpoint, dropping any deliveries still queued for it
+func (a *apiConfig) deleteWebhookEndpointHandler(response http.ResponseWriter, r *http.Request) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid webhook endpoint ID")
+		return
+	}
+	deleted, err := a.store.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: currentPrincipal(r).UserID,
+	})
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	if deleted == 0 {
+		errorResponse(response, http.StatusNotFound, "Webhook endpoint not found")
+		return
+	}
+	noContentResponse(response, "Webhook endpoint deleted")
+}
+
+// show the most recent deliveries to one of the user's endpoints
+func (a *apiConfig) listWebhookDeliveriesHandler(response http.ResponseWriter, r *http.Request) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid webhook endpoint ID")
+		return
+	}
+	_, err = a.store.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: currentPrincipal(r).UserID,
+	})
+	if err == sql.ErrNoRows {
+		errorResponse(response, http.StatusNotFound, "Webhook endpoint not found")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	deliveries, err := a.store.ListWebhookDeliveries(r.Context(), endpointID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonDeliveries := []WebhookDelivery{}
+	for _, delivery := range deliveries {
+		jsonDeliveries = append(jsonDeliveries, jsonSafeWebhookDelivery(delivery))
+	}
+	jsonResponse(response, http.StatusOK, jsonDeliveries, "Webhook deliveries listed")
+}
+
+// only admins may register plain http endpoints
+func validWebhookURL(rawURL string, allowHTTP bool) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || parsed.User != nil || parsed.Fragment != "" {
+		return false
+	}
+	return parsed.Scheme == "https" || (allowHTTP && parsed.Scheme == "http")
+}
+
+// whether every address the URL's host resolves to is public. The sender checks again
+// on each connection, since the name can be re-pointed after it is registered.
+func publicWebhookHost(ctx context.Context, rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if !webhook.PublicAddress(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// queue an event for every endpoint subscribed to it. Failures are logged rather than
+// returned, since the change the event describes has already happened.
+func (a *apiConfig) publishEvent(ctx context.Context, eventType string, subjectUserID uuid.UUID, data interface{}) {
+	payload, err := json.Marshal(outboundEvent{Event: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("Error encoding %v event: %v", eventType, err)
+		return
+	}
+	_, err = a.store.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType:     eventType,
+		Payload:       payload,
+		SubjectUserID: subjectUserID,
+	})
+	if err != nil {
+		log.Printf("Error queueing %v event: %v", eventType, err)
+	}
+}
+
+// send every delivery that is due, in parallel
+func (a *apiConfig) deliverWebhooks(ctx context.Context) error {
+	deliveries, err := a.store.ClaimWebhookDeliveries(ctx, webhookDeliveryBatchSize)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.deliverWebhook(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// attempt one delivery, scheduling a retry with exponential backoff or dead-lettering it on failure
+func (a *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+	statusCode, sendErr := a.webhookSender.Send(ctx, webhook.Delivery{
+		ID:        delivery.ID.String(),
+		URL:       delivery.Url,
+		Secret:    delivery.Secret,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+	})
+	lastStatusCode := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	lastError := sql.NullString{}
+	if sendErr != nil {
+		lastError = sql.NullString{String: truncate(sendErr.Error(), 512), Valid: true}
+		if statusCode == 0 {
+			// the owner sees last_error, so a failure to connect does not describe what was dialled
+			log.Printf("Webhook delivery %v could not reach its receiver: %v", delivery.ID, sendErr)
+			lastError.String = "receiver could not be reached"
+		}
+	}
+	var err error
+	if sendErr == nil {
+		err = a.store.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: lastStatusCode,
+		})
+	} else {
+		attempts := int(delivery.Attempts) + 1
+		status := "pending"
+		if attempts >= maxWebhookDeliveryAttempts {
+			status = "dead"
+			log.Printf("Webhook delivery %v dead-lettered after %v attempts: %v", delivery.ID, attempts, sendErr)
+		}
+		err = a.store.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+			ID:             delivery.ID,
+			Status:         status,
+			NextAttemptAt:  time.Now().Add(webhook.Backoff(attempts)),
+			LastStatusCode: lastStatusCode,
+			LastError:      lastError,
+		})
+	}
+	if err != nil {
+		// the lease runs out and the delivery is retried
+		log.Printf("Error recording webhook delivery %v: %v", delivery.ID, err)
+	}
+}
+
+// Parse generated WebhookEndpoint struct into local json WebhookEndpoint struct - no Secret transferred
+func jsonSafeWebhookEndpoint(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+// Parse generated WebhookDelivery struct into local json WebhookDelivery struct
+func jsonSafeWebhookDelivery(delivery database.WebhookDelivery) WebhookDelivery {
+	jsonDelivery := WebhookDelivery{
+		ID:             delivery.ID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode.Int32,
+		LastError:      delivery.LastError.String,
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.Status == "pending" {
+		jsonDelivery.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.DeliveredAt.Valid {
+		jsonDelivery.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return jsonDelivery
+}
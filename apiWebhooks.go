@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const maxWebhookBodySize = 1 << 20
+
+const webhookProviderPolka = "polka"
+
+// what became of a received webhook event
+const (
+	webhookEventProcessing = "processing"
+	webhookEventProcessed  = "processed"
+	webhookEventIgnored    = "ignored"
+	webhookEventFailed     = "failed"
+)
+
+var errInvalidSubscriberID = errors.New("invalid user ID in event")
+
+// a Polka billing event; ID is absent from events sent before Polka numbered them
+type polkaEvent struct {
+	ID    string         `json:"id"`
+	Event string         `json:"event"`
+	Data  polkaEventData `json:"data"`
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+// handle Polka's billing webhooks, which must be signed with one of the accepted Polka keys.
+// Every event is recorded, and redeliveries of one already handled are acknowledged without reapplying it.
+func (a *apiConfig) upgradeAccount(response http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !a.polkaWebhookAuthenticated(response, r, body) {
+		return
+	}
+
+	upgradeData := polkaEvent{}
+	err = json.Unmarshal(body, &upgradeData)
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	event, err := a.store.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   polkaEventID(r, body, upgradeData.ID),
+		EventType: upgradeData.Event,
+		Payload:   body,
+	})
+	if err == sql.ErrNoRows {
+		noContentResponse(response, "Duplicate webhook event")
+		return
+	} else if err != nil {
+		// anything but a 2xx makes Polka retry later
+		log.Printf("Error recording webhook event: %v", err)
+		errorResponse(response, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	status, err := a.processPolkaEvent(r.Context(), event)
+	switch {
+	case err == nil && status == webhookEventIgnored:
+		noContentResponse(response, "Event not supported")
+	case err == nil:
+		noContentResponse(response, "Subscription updated for "+event.EventType)
+	case err == errUnknownSubscriber:
+		errorResponse(response, http.StatusNotFound, "User not found")
+	case err == errInvalidSubscriberID:
+		errorResponse(response, http.StatusBadRequest, "Invalid user ID")
+	default:
+		log.Printf("Error processing webhook event %v: %v", event.ID, err)
+		errorResponse(response, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+// the ID Polka gave an event, so every redelivery maps to the same record. A signed event
+// without one is identified by its signed timestamp and content; two genuine events can
+// share a body, but not also the second they were signed in. An unsigned event without an
+// ID is never treated as a redelivery, since nothing tells a retry from a genuine repeat.
+func polkaEventID(r *http.Request, body []byte, bodyID string) string {
+	if bodyID != "" {
+		return bodyID
+	}
+	if headerID := r.Header.Get(webhook.IDHeader); headerID != "" {
+		return headerID
+	}
+	if r.Header.Get(webhook.SignatureHeader) != "" {
+		sum := sha256.Sum256(append([]byte(r.Header.Get(webhook.TimestampHeader)+"."), body...))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return "unidentified:" + uuid.NewString()
+}
+
+// apply a recorded Polka event and record the outcome
+func (a *apiConfig) processPolkaEvent(ctx context.Context, event database.WebhookEvent) (string, error) {
+	status, err := a.applyPolkaEvent(ctx, event)
+	lastError := sql.NullString{}
+	if err != nil {
+		status = webhookEventFailed
+		lastError = sql.NullString{String: err.Error(), Valid: true}
+	}
+	finishErr := a.store.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:        event.ID,
+		Status:    status,
+		LastError: lastError,
+	})
+	if finishErr != nil {
+		log.Printf("Error recording outcome of webhook event %v: %v", event.ID, finishErr)
+		if err == nil {
+			err = finishErr
+		}
+	}
+	return status, err
+}
+
+func (a *apiConfig) applyPolkaEvent(ctx context.Context, event database.WebhookEvent) (string, error) {
+	payload := polkaEvent{}
+	err := json.Unmarshal(event.Payload, &payload)
+	if err != nil {
+		return webhookEventFailed, err
+	}
+	handleEvent, ok := subscriptionEvents[payload.Event]
+	if !ok {
+		return webhookEventIgnored, nil
+	}
+	userID, err := uuid.Parse(payload.Data.UserID)
+	if err != nil {
+		return webhookEventFailed, errInvalidSubscriberID
+	}
+	err = handleEvent(a, ctx, userID, payload.Data)
+	if err != nil {
+		return webhookEventFailed, err
+	}
+	return webhookEventProcessed, nil
+}
+
+// check a Polka webhook's signature, falling back to the ApiKey header for
+// requests sent before Polka signed its payloads unless signatures are required
+func (a *apiConfig) polkaWebhookAuthenticated(response http.ResponseWriter, r *http.Request, body []byte) bool {
+	if r.Header.Get(webhook.SignatureHeader) != "" || a.polkaRequireSignature {
+		err := a.polkaWebhook.Verify(r.Header, body)
+		if err != nil {
+			log.Printf("Rejected Polka webhook: %v", err)
+			errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid webhook signature")
+			return false
+		}
+		return true
+	}
+	incKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		errorResponse(response, http.StatusUnauthorized, fmt.Sprintf("Unauthorized: %v", err.Error()))
+		return false
+	}
+	if !a.polkaWebhook.ValidKey(incKey) {
+		errorResponse(response, http.StatusUnauthorized, "Unauthorized: Invalid API key")
+		return false
+	}
+	return true
+}
+
+// list the most recent webhook events, optionally only those with ?status=
+func (a *apiConfig) listWebhookEventsHandler(response http.ResponseWriter, r *http.Request) {
+	status := sql.NullString{}
+	if filter := r.URL.Query().Get("status"); filter != "" {
+		switch filter {
+		case webhookEventProcessing, webhookEventProcessed, webhookEventIgnored, webhookEventFailed:
+			status = sql.NullString{String: filter, Valid: true}
+		default:
+			errorResponse(response, http.StatusBadRequest, "Invalid status")
+			return
+		}
+	}
+	events, err := a.store.ListWebhookEvents(r.Context(), status)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonEvents := []WebhookEvent{}
+	for _, event := range events {
+		jsonEvents = append(jsonEvents, jsonSafeWebhookEvent(event))
+	}
+	jsonResponse(response, http.StatusOK, jsonEvents, "Webhook events listed")
+}
+
+// process a failed webhook event again, once whatever made it fail has been fixed, or one
+// left stuck in processing by a crash once it has sat there for five minutes
+func (a *apiConfig) replayWebhookEventHandler(response http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		errorResponse(response, http.StatusBadRequest, "Invalid webhook event ID")
+		return
+	}
+	event, err := a.store.RetryWebhookEvent(r.Context(), eventID)
+	if err == sql.ErrNoRows {
+		errorResponse(response, http.StatusNotFound, "No failed or stalled webhook event with that ID")
+		return
+	} else if err != nil {
+		internalError(response, err)
+		return
+	}
+	log.Printf("User %v replaying webhook event %v", currentPrincipal(r).UserID, event.ID)
+	_, err = a.processPolkaEvent(r.Context(), event)
+	if err != nil {
+		log.Printf("Replay of webhook event %v failed: %v", event.ID, err)
+	}
+	event, err = a.store.GetWebhookEvent(r.Context(), event.ID)
+	if err != nil {
+		internalError(response, err)
+		return
+	}
+	jsonResponse(response, http.StatusOK, jsonSafeWebhookEvent(event), "Webhook event replayed")
+}
+
+// Parse generated WebhookEvent struct into local json WebhookEvent struct
+func jsonSafeWebhookEvent(event database.WebhookEvent) WebhookEvent {
+	jsonEvent := WebhookEvent{
+		ID:         event.ID,
+		Provider:   event.Provider,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		LastError:  event.LastError.String,
+		ReceivedAt: event.ReceivedAt,
+	}
+	if event.ProcessedAt.Valid {
+		jsonEvent.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return jsonEvent
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/store"
+)
+
+const usage = `usage: serverProject [command]
+
+Without a command the server is started.
+
+commands:
+  promote-admin <email>    give an existing user the admin role
+`
+
+// run a maintenance command instead of the server, returning the exit code
+func runCommand(db store.Store, args []string) int {
+	switch args[0] {
+	case "promote-admin":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		return promoteAdmin(db, args[1])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// bootstrap the first admin, who can then reach the /admin/ routes
+func promoteAdmin(db store.Store, email string) int {
+	updated, err := db.SetUserRole(context.Background(), database.SetUserRoleParams{
+		Role:  auth.RoleAdmin,
+		Email: email,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error promoting %v: %v\n", email, err)
+		return 1
+	}
+	if updated == 0 {
+		fmt.Fprintf(os.Stderr, "No user with email %v\n", email)
+		return 1
+	}
+	fmt.Printf("%v is now an admin; they need to log in again to pick up the role\n", email)
+	return 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/store"
+	"github.com/google/uuid"
+)
+
+// how long a cached per-user watermark is trusted before it is read again
+const watermarkCacheLifetime = 30 * time.Second
+
+// accessTokenDenylist tracks access tokens revoked before they expire, either
+// one at a time by jti or per user through a "tokens issued before" watermark.
+// The store is the source of truth; the in-memory copy is what requests check.
+type accessTokenDenylist struct {
+	db store.Store
+
+	mu         sync.RWMutex
+	denied     map[uuid.UUID]time.Time
+	watermarks map[uuid.UUID]cachedWatermark
+}
+
+type cachedWatermark struct {
+	validAfter sql.NullTime
+	fetchedAt  time.Time
+}
+
+func newAccessTokenDenylist(db store.Store) *accessTokenDenylist {
+	return &accessTokenDenylist{
+		db:         db,
+		denied:     map[uuid.UUID]time.Time{},
+		watermarks: map[uuid.UUID]cachedWatermark{},
+	}
+}
+
+// Deny revokes the access token a principal authenticated with until it expires.
+func (d *accessTokenDenylist) Deny(ctx context.Context, principal *auth.Principal) error {
+	jti, err := uuid.Parse(principal.TokenID)
+	if err != nil {
+		// tokens issued before jti was added cannot be denied individually
+		return nil
+	}
+	err = d.db.DenyAccessToken(ctx, database.DenyAccessTokenParams{
+		Jti:       jti,
+		UserID:    principal.UserID,
+		ExpiresAt: principal.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.denied[jti] = principal.ExpiresAt
+	d.mu.Unlock()
+	return nil
+}
+
+// RevokeIssuedBefore revokes every access token issued to a user before now.
+func (d *accessTokenDenylist) RevokeIssuedBefore(ctx context.Context, userID uuid.UUID) error {
+	// iat has one second resolution, so tokens issued later in this second stay valid
+	validAfter := sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+	err := d.db.RevokeTokensIssuedBefore(ctx, database.RevokeTokensIssuedBeforeParams{
+		TokensValidAfter: validAfter,
+		ID:               userID,
+	})
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.watermarks[userID] = cachedWatermark{validAfter: validAfter, fetchedAt: time.Now()}
+	d.mu.Unlock()
+	return nil
+}
+
+// Revoked reports whether a validated access token has since been revoked.
+func (d *accessTokenDenylist) Revoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	jti, err := uuid.Parse(claims.ID)
+	if err == nil {
+		d.mu.RLock()
+		_, denied := d.denied[jti]
+		d.mu.RUnlock()
+		if denied {
+			return true, nil
+		}
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return true, err
+	}
+	watermark, err := d.watermark(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return watermark.Valid && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(watermark.Time), nil
+}
+
+// the user's watermark, read from the database when the cached copy is stale
+func (d *accessTokenDenylist) watermark(ctx context.Context, userID uuid.UUID) (sql.NullTime, error) {
+	d.mu.RLock()
+	cached, ok := d.watermarks[userID]
+	d.mu.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < watermarkCacheLifetime {
+		return cached.validAfter, nil
+	}
+	validAfter, err := d.db.GetTokensValidAfter(ctx, userID)
+	if err == sql.ErrNoRows {
+		// the user was deleted, so nothing they hold is valid any more
+		return sql.NullTime{Time: time.Now(), Valid: true}, nil
+	} else if err != nil {
+		return sql.NullTime{}, err
+	}
+	d.mu.Lock()
+	d.watermarks[userID] = cachedWatermark{validAfter: validAfter, fetchedAt: time.Now()}
+	d.mu.Unlock()
+	return validAfter, nil
+}
+
+// Sync drops expired entries from the table and the cache, then reloads
+// the cache so denials made by other instances are picked up.
+func (d *accessTokenDenylist) Sync(ctx context.Context) error {
+	_, err := d.db.PruneDeniedAccessTokens(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := d.db.ListDeniedAccessTokens(ctx)
+	if err != nil {
+		return err
+	}
+	denied := make(map[uuid.UUID]time.Time, len(rows))
+	for _, row := range rows {
+		denied[row.Jti] = row.ExpiresAt
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.denied = denied
+	for userID, cached := range d.watermarks {
+		if now.Sub(cached.fetchedAt) > watermarkCacheLifetime {
+			delete(d.watermarks, userID)
+		}
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/Lokee86/serverProject/internal/auth"
 	"github.com/Lokee86/serverProject/internal/database"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // readiness end point response - 200 OK
@@ -17,6 +21,17 @@ func healthCheck(response http.ResponseWriter, r *http.Request) {
 	log.Println("Health check OK")
 }
 
+// publish the public keys access tokens can be verified with
+func jwksHandler(response http.ResponseWriter, r *http.Request) {
+	keys := auth.JWKSet{Keys: []auth.JWK{}}
+	if auth.DefaultKeyring != nil {
+		keys = auth.DefaultKeyring.JWKS()
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("Cache-Control", "public, max-age=300")
+	jsonResponse(response, http.StatusOK, keys, "JWKS served")
+}
+
 // check and filter profanity, no return, modifies at memory address
 func checkProfanity(chirp *string) {
 	profaneWords := []string{"kerfuffle", "sharbert", "fornax"}
@@ -79,11 +94,14 @@ func jsonSafeChirp(chirp database.Chirp) Chirp {
 // Parse generated User struct into local json controled User struct - no HashedPassword field transferred
 func jsonReturnUser(user database.User) User {
 	newUserJson := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+		Role:          user.Role,
 	}
 	return newUserJson
 }
@@ -103,6 +121,35 @@ func extractIDString(response http.ResponseWriter, path string) uuid.UUID {
 	return idStr
 }
 
+// report whether a database error is a violated unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// report whether a database error is a violated foreign key, such as a reference to a missing user
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// address of the client that made a request, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// cut a string down to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
 // return a 204 No Content response
 func noContentResponse(response http.ResponseWriter, mesg string) {
 	response.WriteHeader(http.StatusNoContent)
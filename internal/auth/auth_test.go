@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -81,3 +84,263 @@ func TestValidateJWT_InvalidSignature(t *testing.T) {
 		t.Fatal("expected error for invalid signature, got none")
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	oldKey := TokenHashKey
+	TokenHashKey = "first-key"
+	defer func() { TokenHashKey = oldKey }()
+
+	hash := HashToken("some-token")
+	if hash != HashToken("some-token") {
+		t.Fatal("expected hashing the same token twice to give the same hash")
+	}
+	if hash == HashToken("other-token") {
+		t.Fatal("expected different tokens to give different hashes")
+	}
+
+	TokenHashKey = "second-key"
+	if hash == HashToken("some-token") {
+		t.Fatal("expected a different key to give a different hash")
+	}
+}
+
+func TestEmailVerificationToken(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeEmailVerificationToken(userID, "user@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken failed: %v", err)
+	}
+
+	parsedID, email, err := ValidateEmailVerificationToken(token)
+	if err != nil {
+		t.Fatalf("ValidateEmailVerificationToken failed: %v", err)
+	}
+	if parsedID != userID || email != "user@example.com" {
+		t.Errorf("Expected %v and user@example.com, got %v and %v", userID, parsedID, email)
+	}
+
+	if _, err := ValidateJWT(token); err == nil {
+		t.Fatal("expected verification token to be rejected as an access token")
+	}
+	accessToken, _ := MakeJWT(userID, time.Minute)
+	if _, _, err := ValidateEmailVerificationToken(accessToken); err == nil {
+		t.Fatal("expected access token to be rejected as a verification token")
+	}
+}
+
+func TestMakeJWTWithSessionID(t *testing.T) {
+	userID, sessionID := uuid.New(), uuid.New()
+	token, err := MakeJWT(userID, time.Minute, WithSessionID(sessionID))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken failed: %v", err)
+	}
+	if claims.Subject != userID.String() || claims.SessionID != sessionID.String() {
+		t.Errorf("Expected subject %v and session %v, got %v and %v", userID, sessionID, claims.Subject, claims.SessionID)
+	}
+	if _, err := uuid.Parse(claims.ID); err != nil {
+		t.Errorf("expected a UUID jti, got %q", claims.ID)
+	}
+}
+
+func TestPrincipalAuthTime(t *testing.T) {
+	signedIn := time.Now().Add(-time.Minute).Truncate(time.Second)
+	token, _ := MakeJWT(uuid.New(), time.Minute, WithAuthTime(signedIn))
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken failed: %v", err)
+	}
+	principal, err := PrincipalFromClaims(claims)
+	if err != nil {
+		t.Fatalf("PrincipalFromClaims failed: %v", err)
+	}
+	if !principal.AuthTime.Equal(signedIn) {
+		t.Errorf("expected auth time %v, got %v", signedIn, principal.AuthTime)
+	}
+
+	refreshed, _ := MakeJWT(uuid.New(), time.Minute)
+	claims, _ = ParseAccessToken(refreshed)
+	principal, _ = PrincipalFromClaims(claims)
+	if !principal.AuthTime.IsZero() {
+		t.Errorf("expected no auth time without the claim, got %v", principal.AuthTime)
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	full := &Principal{UserID: uuid.New()}
+	if !full.HasScope("chirps:write") {
+		t.Error("expected principal without scopes to have every scope")
+	}
+	limited := &Principal{UserID: uuid.New(), Scopes: []string{"chirps:read"}}
+	if !limited.HasScope("chirps:read") || limited.HasScope("chirps:write") {
+		t.Errorf("expected only chirps:read, got %v", limited.Scopes)
+	}
+	ctx := WithPrincipal(context.Background(), limited)
+	if principal, ok := PrincipalFromContext(ctx); !ok || principal != limited {
+		t.Error("expected principal to round trip through the context")
+	}
+}
+
+func TestPrincipalRoles(t *testing.T) {
+	token, err := MakeJWT(uuid.New(), time.Minute, WithRole(RoleModerator))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken failed: %v", err)
+	}
+	principal, err := PrincipalFromClaims(claims)
+	if err != nil {
+		t.Fatalf("PrincipalFromClaims failed: %v", err)
+	}
+	if !principal.HasRole(RoleUser) || !principal.HasRole(RoleModerator) || principal.HasRole(RoleAdmin) {
+		t.Errorf("expected moderator to have user and moderator roles only")
+	}
+
+	legacy, _ := MakeJWT(uuid.New(), time.Minute)
+	claims, _ = ParseAccessToken(legacy)
+	principal, _ = PrincipalFromClaims(claims)
+	if principal.Role != RoleUser {
+		t.Errorf("expected token without a role claim to act as %v, got %v", RoleUser, principal.Role)
+	}
+	if principal.HasRole("superuser") {
+		t.Error("expected unknown roles never to be granted")
+	}
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken failed: %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("expected %q to carry the %q prefix", token, PersonalAccessTokenPrefix)
+	}
+	if IsPersonalAccessToken("f271c81ff7084ee5b99a5091b42d486e") {
+		t.Error("expected a plain API key not to look like a personal access token")
+	}
+	if !ValidScope(ScopeChirpsWrite) || ValidScope("admin") {
+		t.Error("expected only known scopes to be valid")
+	}
+}
+
+func TestPKCE(t *testing.T) {
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got := S256CodeChallenge(verifier); got != challenge {
+		t.Errorf("expected challenge %v, got %v", challenge, got)
+	}
+	if !ValidCodeChallenge(challenge) || !VerifyPKCE(verifier, challenge) {
+		t.Error("expected the RFC example verifier to match its challenge")
+	}
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Error("expected a different verifier to be rejected")
+	}
+	if VerifyPKCE("short", S256CodeChallenge("short")) {
+		t.Error("expected a verifier under 43 characters to be rejected")
+	}
+}
+
+func TestClientAccessTokenScopes(t *testing.T) {
+	clientID := uuid.New()
+	token, err := MakeJWT(uuid.New(), time.Minute, WithClientID(clientID), WithScopes([]string{ScopeChirpsRead}))
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	claims, err := ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken failed: %v", err)
+	}
+	principal, err := PrincipalFromClaims(claims)
+	if err != nil {
+		t.Fatalf("PrincipalFromClaims failed: %v", err)
+	}
+	if principal.ClientID != clientID {
+		t.Errorf("expected client %v, got %v", clientID, principal.ClientID)
+	}
+	if !principal.HasScope(ScopeChirpsRead) || principal.HasScope(ScopeChirpsWrite) {
+		t.Errorf("expected only %v, got %v", ScopeChirpsRead, principal.Scopes)
+	}
+
+	unscoped, _ := MakeJWT(uuid.New(), time.Minute, WithClientID(clientID))
+	claims, _ = ParseAccessToken(unscoped)
+	principal, _ = PrincipalFromClaims(claims)
+	if principal.Scopes == nil || principal.HasScope(ScopeChirpsRead) {
+		t.Error("expected a client token without scopes to be granted nothing")
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	login, token, err := MakeOIDCLogin(true, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeOIDCLogin failed: %v", err)
+	}
+	if !ValidCodeVerifier(login.CodeVerifier) || login.State == login.Nonce {
+		t.Errorf("expected distinct state and nonce and a valid verifier, got %+v", login)
+	}
+	got, err := ValidateOIDCLogin(token)
+	if err != nil || got != login {
+		t.Errorf("expected %+v to round trip, got %+v (%v)", login, got, err)
+	}
+	accessToken, _ := MakeJWT(uuid.New(), time.Minute)
+	if _, err := ValidateOIDCLogin(accessToken); err == nil {
+		t.Error("expected an access token to be rejected as a sign in state")
+	}
+}
+
+func TestMagicLinkToken(t *testing.T) {
+	userID := uuid.New()
+	first, err := MakeMagicLinkToken(userID, "user@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMagicLinkToken failed: %v", err)
+	}
+	second, _ := MakeMagicLinkToken(userID, "user@example.com", time.Minute)
+	if first == second {
+		t.Error("expected every magic link to be distinct")
+	}
+	gotID, gotEmail, err := ValidateMagicLinkToken(first)
+	if err != nil || gotID != userID || gotEmail != "user@example.com" {
+		t.Errorf("expected %v and user@example.com, got %v and %v (%v)", userID, gotID, gotEmail, err)
+	}
+	verification, _ := MakeEmailVerificationToken(userID, "user@example.com", time.Minute)
+	if _, _, err := ValidateMagicLinkToken(verification); err == nil {
+		t.Error("expected an email verification token to be rejected as a magic link")
+	}
+	expired, _ := MakeMagicLinkToken(userID, "user@example.com", -time.Minute)
+	if _, _, err := ValidateMagicLinkToken(expired); err == nil {
+		t.Error("expected an expired magic link to be rejected")
+	}
+}
+
+func TestGetAccessTokenFromCookie(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps", nil)
+	r.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: "from-cookie"})
+	token, fromCookie, err := GetAccessToken(r)
+	if err != nil || token != "from-cookie" || !fromCookie {
+		t.Errorf("expected the cookie token, got %q %v (%v)", token, fromCookie, err)
+	}
+	r.Header.Set("Authorization", "Bearer from-header")
+	token, fromCookie, err = GetAccessToken(r)
+	if err != nil || token != "from-header" || fromCookie {
+		t.Errorf("expected the Authorization header to win, got %q %v (%v)", token, fromCookie, err)
+	}
+	if _, _, err := GetAccessToken(httptest.NewRequest(http.MethodGet, "/", nil)); err != ErrNoAccessToken {
+		t.Errorf("expected ErrNoAccessToken, got %v", err)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	sessionID := uuid.New()
+	token := CSRFToken(sessionID)
+	if !ValidCSRFToken(sessionID, token) {
+		t.Error("expected the session's CSRF token to be valid")
+	}
+	if ValidCSRFToken(uuid.New(), token) || ValidCSRFToken(sessionID, "") || ValidCSRFToken(uuid.Nil, CSRFToken(uuid.Nil)) {
+		t.Error("expected CSRF tokens to be bound to a real session")
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const twoFactorChallengeIssuer = "chirpy-2fa-challenge"
+
+// MakeTwoFactorChallenge signs a token showing userID passed the password
+// step of a login, to be exchanged together with a second factor.
+func MakeTwoFactorChallenge(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		Issuer:    twoFactorChallengeIssuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+	}
+	return signClaims(claims)
+}
+
+// ValidateTwoFactorChallenge returns the user a challenge token was issued to.
+func ValidateTwoFactorChallenge(tokenString string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithIssuer(twoFactorChallengeIssuer))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(claims.Subject)
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Cookies and header used by browser clients that keep their tokens in
+// HttpOnly cookies instead of script-readable storage.
+const (
+	AccessTokenCookie  = "chirpy_access"
+	RefreshTokenCookie = "chirpy_refresh"
+	// CSRFCookie is readable by scripts so the frontend can echo it in CSRFHeader
+	CSRFCookie = "chirpy_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+var (
+	ErrNoAccessToken  = errors.New("no access token in Authorization header or cookie")
+	ErrNoRefreshToken = errors.New("no refresh token in Authorization header or cookie")
+)
+
+// GetAccessToken returns the access token sent with a request, preferring an
+// Authorization bearer token over the access token cookie. fromCookie tells
+// the caller the request needs CSRF protection.
+func GetAccessToken(r *http.Request) (token string, fromCookie bool, err error) {
+	if r.Header.Get("Authorization") != "" {
+		token, err = GetBearerToken(r.Header)
+		return token, false, err
+	}
+	cookie, err := r.Cookie(AccessTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false, ErrNoAccessToken
+	}
+	return cookie.Value, true, nil
+}
+
+// GetRefreshToken is GetAccessToken for the refresh token.
+func GetRefreshToken(r *http.Request) (token string, fromCookie bool, err error) {
+	if r.Header.Get("Authorization") != "" {
+		token, err = GetBearerToken(r.Header)
+		return token, false, err
+	}
+	cookie, err := r.Cookie(RefreshTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false, ErrNoRefreshToken
+	}
+	return cookie.Value, true, nil
+}
+
+// CSRFToken is the anti-forgery token for a cookie session. It is derived
+// from the session with the token hash key, so it cannot be forged or planted
+// by another site and needs no storage.
+func CSRFToken(sessionID uuid.UUID) string {
+	return HashToken("csrf:" + sessionID.String())
+}
+
+// ValidCSRFToken reports whether token is the anti-forgery token for sessionID.
+func ValidCSRFToken(sessionID uuid.UUID, token string) bool {
+	if sessionID == uuid.Nil || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(CSRFToken(sessionID)), []byte(token))
+}
+
+// SafeMethod reports whether method is one CSRF protection can skip because it must not change state.
+func SafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const emailVerificationIssuer = "chirpy-email-verification"
+
+type emailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// MakeEmailVerificationToken signs a token proving that whoever holds it can
+// read mail sent to email on behalf of userID.
+func MakeEmailVerificationToken(userID uuid.UUID, email string, expiresIn time.Duration) (string, error) {
+	claims := emailVerificationClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    emailVerificationIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+	}
+	return signClaims(claims)
+}
+
+// ValidateEmailVerificationToken returns the user and email address a verification token was issued for.
+func ValidateEmailVerificationToken(tokenString string) (uuid.UUID, string, error) {
+	claims := &emailVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithIssuer(emailVerificationIssuer))
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Email, nil
+}
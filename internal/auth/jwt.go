@@ -15,37 +15,100 @@ import (
 
 var TokenSecret string
 
+// other token types are signed with the same keys, so the issuer keeps them from passing as access tokens
+const accessTokenIssuer = "chirpy"
+
 func init() {
 	godotenv.Load()
 	TokenSecret = os.Getenv("JWT_SECRET")
 }
 
-func MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   userID.String(),
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+// Claims are the claims carried by access tokens.
+type Claims struct {
+	// SessionID is the refresh token family the access token was issued from
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	// Scope is the space separated list of scopes a delegated token was granted; empty grants everything
+	Scope string `json:"scope,omitempty"`
+	// ClientID is the OAuth client the token was issued to
+	ClientID string `json:"client_id,omitempty"`
+	// AuthTime is when the user last proved who they are; tokens minted by a refresh leave it unset
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenOption adds optional claims to an access token.
+type TokenOption func(*Claims)
+
+func WithSessionID(sessionID uuid.UUID) TokenOption {
+	return func(c *Claims) {
+		c.SessionID = sessionID.String()
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func WithRole(role string) TokenOption {
+	return func(c *Claims) {
+		c.Role = role
+	}
+}
 
-	signedToken, err := token.SignedString([]byte(TokenSecret))
-	if err != nil {
-		return "", err
+// WithScopes limits an access token to scopes.
+func WithScopes(scopes []string) TokenOption {
+	return func(c *Claims) {
+		c.Scope = strings.Join(scopes, " ")
 	}
-	return signedToken, nil
+}
+
+// WithClientID marks an access token as issued to an OAuth client.
+func WithClientID(clientID uuid.UUID) TokenOption {
+	return func(c *Claims) {
+		c.ClientID = clientID.String()
+	}
+}
+
+// WithAuthTime records when the user signed in to obtain the token.
+func WithAuthTime(authTime time.Time) TokenOption {
+	return func(c *Claims) {
+		c.AuthTime = jwt.NewNumericDate(authTime.UTC())
+	}
+}
+
+func MakeJWT(userID uuid.UUID, expiresIn time.Duration, options ...TokenOption) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   userID.String(),
+			Issuer:    accessTokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+	}
+	for _, option := range options {
+		option(&claims)
+	}
+
+	return signClaims(claims)
 }
 
 func ValidateJWT(tokenString string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
-	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	claims, err := ParseAccessToken(tokenString)
 	if err != nil {
 		return uuid.Nil, err
 	}
 	return uuid.Parse(claims.Subject)
 }
 
+// ParseAccessToken validates an access token and returns all of its claims.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithIssuer(accessTokenIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 func GetBearerToken(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if !strings.HasPrefix(authHeader, "Bearer ") {
@@ -62,7 +125,24 @@ func GetAPIKey(headers http.Header) (string, error) {
 	return strings.TrimPrefix(authHeader, "ApiKey "), nil
 }
 
+// sign claims with the default keyring, or HS256 and TokenSecret when there is none
+func signClaims(claims jwt.Claims) (string, error) {
+	if DefaultKeyring != nil {
+		return DefaultKeyring.Sign(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	signedToken, err := token.SignedString([]byte(TokenSecret))
+	if err != nil {
+		return "", err
+	}
+	return signedToken, nil
+}
+
 func keyFunc(t *jwt.Token) (interface{}, error) {
+	if DefaultKeyring != nil {
+		return DefaultKeyring.Keyfunc(t)
+	}
 	if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 		return nil, fmt.Errorf("unexpected signing method")
 	}
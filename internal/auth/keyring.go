@@ -0,0 +1,434 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKeyring signs and verifies access tokens. When it is nil tokens are
+// signed with HS256 using TokenSecret.
+var DefaultKeyring *Keyring
+
+// SigningKey is a key access tokens are signed or verified with. Private is
+// nil for keys that are only kept around to verify tokens during rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+// Keyring holds the key new tokens are signed with and every key that tokens
+// are still accepted from, looked up by the kid header.
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// JWK is the public half of a signing key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served from the JWKS endpoint.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string]*SigningKey{}}
+}
+
+// NewHMACKey creates an HS256 key. HMAC keys are never published in the JWKS.
+func NewHMACKey(kid, secret string) *SigningKey {
+	return &SigningKey{
+		ID:      kid,
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}
+}
+
+// NewSigningKey creates a key from an RSA, ECDSA or Ed25519 private key. The
+// algorithm follows from the key type, and the kid defaults to the RFC 7638
+// thumbprint of the public key when empty.
+func NewSigningKey(kid string, private crypto.Signer) (*SigningKey, error) {
+	key, err := NewVerificationKey(kid, private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Private = private
+	return key, nil
+}
+
+// NewVerificationKey creates a key that can only verify tokens.
+func NewVerificationKey(kid string, public crypto.PublicKey) (*SigningKey, error) {
+	key := &SigningKey{ID: kid, Public: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	if key.ID == "" {
+		thumbprint, err := key.thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+	return key, nil
+}
+
+// SetSigningKey makes key the one new tokens are signed with. Tokens signed
+// with the previous signing key keep verifying until it is removed.
+func (k *Keyring) SetSigningKey(key *SigningKey) error {
+	if key.Private == nil {
+		return errors.New("signing key has no private key")
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.signing = key
+	k.keys[key.ID] = key
+	return nil
+}
+
+// AddVerificationKey accepts tokens signed with key without signing new ones.
+func (k *Keyring) AddVerificationKey(key *SigningKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key
+}
+
+// RemoveKey stops accepting tokens signed with the key with the given kid.
+func (k *Keyring) RemoveKey(kid string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, kid)
+	if k.signing != nil && k.signing.ID == kid {
+		k.signing = nil
+	}
+}
+
+// Sign signs claims with the current signing key, setting the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	key := k.signing
+	k.mu.RUnlock()
+	if key == nil {
+		return "", errors.New("keyring has no signing key")
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc finds the verification key for a token, refusing tokens whose
+// algorithm does not match the key. A token without a kid is checked against
+// every key for its algorithm.
+func (k *Keyring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	candidates := jwt.VerificationKeySet{}
+	if !ok && kid == "" {
+		for _, key := range k.keys {
+			if key.Method.Alg() == t.Method.Alg() {
+				candidates.Keys = append(candidates.Keys, key.Public)
+			}
+		}
+	}
+	k.mu.RUnlock()
+	if !ok {
+		// tokens signed before the keyring was configured carry no kid
+		if _, isHMAC := t.Method.(*jwt.SigningMethodHMAC); kid == "" && isHMAC && TokenSecret != "" {
+			candidates.Keys = append(candidates.Keys, []byte(TokenSecret))
+		}
+		if len(candidates.Keys) == 0 {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return candidates, nil
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", t.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public keys of every asymmetric key in the keyring.
+func (k *Keyring) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk, err := key.JWK()
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// JWK returns the public key in JWK form. HMAC keys have no public form.
+func (key *SigningKey) JWK() (JWK, error) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64URL(pub.N.Bytes())
+		jwk.E = base64URL(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64URL(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64URL(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64URL(pub)
+	default:
+		return JWK{}, fmt.Errorf("key %q has no public JWK form", key.ID)
+	}
+	return jwk, nil
+}
+
+// ParseJWK builds a verification key from a published JWK. An alg member
+// overrides the algorithm inferred from the key type; symmetric algorithms
+// are refused so a published key can never be used as an HMAC secret.
+func ParseJWK(jwk JWK) (*SigningKey, error) {
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return nil, fmt.Errorf("key %q is not a signing key", jwk.Kid)
+	}
+	var public crypto.PublicKey
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent out of range")
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		ecKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		public = ecKey
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported OKP key %q", jwk.Crv)
+		}
+		public = ed25519.PublicKey(x)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	key, err := NewVerificationKey(jwk.Kid, public)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Alg != "" {
+		method := jwt.GetSigningMethod(jwk.Alg)
+		if _, isHMAC := method.(*jwt.SigningMethodHMAC); method == nil || isHMAC || method == jwt.SigningMethodNone {
+			return nil, fmt.Errorf("unsupported algorithm %q for key %q", jwk.Alg, jwk.Kid)
+		}
+		key.Method = method
+	}
+	return key, nil
+}
+
+// RFC 7638 thumbprint, computed over the required members in lexicographic order
+func (key *SigningKey) thumbprint() (string, error) {
+	jwk, err := key.JWK()
+	if err != nil {
+		return "", err
+	}
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64URL(sum[:]), nil
+}
+
+// ParsePrivateKeyPEM parses a PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) private key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKeyPEM parses a PKIX public key, or takes the public half of a private key.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type == "PUBLIC KEY" {
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	private, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return private.Public(), nil
+}
+
+// LoadKeyringFromEnv builds a keyring from JWT_SIGNING_KEY_FILE (a PEM private
+// key, with an optional JWT_SIGNING_KEY_ID) and JWT_VERIFICATION_KEY_FILES (a
+// comma separated list of PEM keys still accepted during rotation). It returns
+// nil when no signing key file is configured, keeping HS256 with JWT_SECRET.
+func LoadKeyringFromEnv() (*Keyring, error) {
+	signingKeyFile := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if signingKeyFile == "" {
+		return nil, nil
+	}
+	keyring := NewKeyring()
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	private, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", signingKeyFile, err)
+	}
+	signingKey, err := NewSigningKey(os.Getenv("JWT_SIGNING_KEY_ID"), private)
+	if err != nil {
+		return nil, err
+	}
+	err = keyring.SetSigningKey(signingKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		public, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		key, err := NewVerificationKey("", public)
+		if err != nil {
+			return nil, err
+		}
+		keyring.AddVerificationKey(key)
+	}
+	return keyring, nil
+}
+
+func base64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestSigners(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key failed: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key failed: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key failed: %v", err)
+	}
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey}
+}
+
+func useKeyring(t *testing.T, keyring *Keyring) {
+	oldKeyring := DefaultKeyring
+	DefaultKeyring = keyring
+	t.Cleanup(func() { DefaultKeyring = oldKeyring })
+}
+
+func TestKeyringSignAndValidate(t *testing.T) {
+	for alg, signer := range newTestSigners(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewSigningKey("", signer)
+			if err != nil {
+				t.Fatalf("NewSigningKey failed: %v", err)
+			}
+			if key.Method.Alg() != alg {
+				t.Fatalf("expected %v, got %v", alg, key.Method.Alg())
+			}
+			keyring := NewKeyring()
+			if err := keyring.SetSigningKey(key); err != nil {
+				t.Fatalf("SetSigningKey failed: %v", err)
+			}
+			useKeyring(t, keyring)
+
+			userID := uuid.New()
+			token, err := MakeJWT(userID, time.Minute)
+			if err != nil {
+				t.Fatalf("MakeJWT failed: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("parsing token failed: %v", err)
+			}
+			if parsed.Header["kid"] != key.ID {
+				t.Errorf("expected kid %v, got %v", key.ID, parsed.Header["kid"])
+			}
+			parsedID, err := ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT failed: %v", err)
+			}
+			if parsedID != userID {
+				t.Errorf("Expected %v, got %v", userID, parsedID)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	signers := newTestSigners(t)
+	oldKey, _ := NewSigningKey("old", signers["RS256"])
+	newKey, _ := NewSigningKey("new", signers["ES256"])
+
+	keyring := NewKeyring()
+	keyring.SetSigningKey(oldKey)
+	useKeyring(t, keyring)
+	oldToken, err := MakeJWT(uuid.New(), time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	keyring.SetSigningKey(newKey)
+	if _, err := ValidateJWT(oldToken); err != nil {
+		t.Fatalf("expected token from previous key to validate during rotation, got: %v", err)
+	}
+	if got := len(keyring.JWKS().Keys); got != 2 {
+		t.Fatalf("expected 2 keys in JWKS during rotation, got %v", got)
+	}
+
+	keyring.RemoveKey("old")
+	if _, err := ValidateJWT(oldToken); err == nil {
+		t.Fatal("expected token from removed key to fail validation")
+	}
+}
+
+func TestKeyringRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, _ := NewSigningKey("rsa", newTestSigners(t)["RS256"])
+	keyring := NewKeyring()
+	keyring.SetSigningKey(rsaKey)
+	useKeyring(t, keyring)
+
+	// an HS256 token signed with the RSA key's kid must not be checked against an HMAC secret
+	claims := jwt.RegisteredClaims{Subject: uuid.NewString(), ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString([]byte("guess"))
+	if err != nil {
+		t.Fatalf("signing forged token failed: %v", err)
+	}
+	if _, err := ValidateJWT(token); err == nil {
+		t.Fatal("expected token with mismatched algorithm to fail validation")
+	}
+}
+
+func TestKeyringJWKSExcludesHMAC(t *testing.T) {
+	keyring := NewKeyring()
+	keyring.SetSigningKey(NewHMACKey("shared", "secret"))
+	if got := len(keyring.JWKS().Keys); got != 0 {
+		t.Fatalf("expected HMAC key to be left out of JWKS, got %v keys", got)
+	}
+}
+
+func TestParseJWKRoundTrip(t *testing.T) {
+	for alg, signer := range newTestSigners(t) {
+		key, err := NewSigningKey("", signer)
+		if err != nil {
+			t.Fatalf("%v: NewSigningKey failed: %v", alg, err)
+		}
+		jwk, err := key.JWK()
+		if err != nil {
+			t.Fatalf("%v: JWK failed: %v", alg, err)
+		}
+		parsed, err := ParseJWK(jwk)
+		if err != nil {
+			t.Fatalf("%v: ParseJWK failed: %v", alg, err)
+		}
+		if parsed.ID != key.ID || parsed.Method.Alg() != alg {
+			t.Errorf("%v: expected kid %v, got %v with %v", alg, key.ID, parsed.ID, parsed.Method.Alg())
+		}
+
+		keyring := NewKeyring()
+		keyring.SetSigningKey(key)
+		signed, _ := keyring.Sign(jwt.RegisteredClaims{Subject: "round-trip"})
+		verifier := NewKeyring()
+		verifier.AddVerificationKey(parsed)
+		if _, err := jwt.Parse(signed, verifier.Keyfunc); err != nil {
+			t.Errorf("%v: expected token to verify with the parsed key: %v", alg, err)
+		}
+	}
+
+	key, _ := NewSigningKey("", newTestSigners(t)["ES256"])
+	jwk, _ := key.JWK()
+	jwk.Alg = "HS256"
+	if _, err := ParseJWK(jwk); err == nil {
+		t.Error("expected a JWK claiming an HMAC algorithm to be refused")
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const magicLinkIssuer = "chirpy-magic-link"
+
+type magicLinkClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// MakeMagicLinkToken signs a token that signs userID in when it comes back
+// from the mailbox at email.
+func MakeMagicLinkToken(userID uuid.UUID, email string, expiresIn time.Duration) (string, error) {
+	claims := magicLinkClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// every link is distinct so each one can be stored and used up on its own
+			ID:        uuid.NewString(),
+			Subject:   userID.String(),
+			Issuer:    magicLinkIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+	}
+	return signClaims(claims)
+}
+
+// ValidateMagicLinkToken returns the user and email address a magic link was issued for.
+func ValidateMagicLinkToken(tokenString string) (uuid.UUID, string, error) {
+	claims := &magicLinkClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithIssuer(magicLinkIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Email, nil
+}
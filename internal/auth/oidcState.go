@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const oidcLoginIssuer = "chirpy-oidc-login"
+
+// OIDCLogin is what the browser has to bring back from an external provider
+// to finish signing in: the state sent to the provider, the nonce expected in
+// the ID token and the PKCE verifier for the code exchange.
+type OIDCLogin struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// CookieSession asks for the session to be handed over in cookies
+	CookieSession bool `json:"cookie_session,omitempty"`
+}
+
+type oidcLoginClaims struct {
+	OIDCLogin
+	jwt.RegisteredClaims
+}
+
+// MakeOIDCLogin starts an external sign in with a fresh state, nonce and PKCE
+// verifier, returning them along with a signed token to keep them in.
+func MakeOIDCLogin(cookieSession bool, expiresIn time.Duration) (OIDCLogin, string, error) {
+	login := OIDCLogin{CookieSession: cookieSession}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.CodeVerifier} {
+		random, err := MakeOpaqueToken()
+		if err != nil {
+			return OIDCLogin{}, "", err
+		}
+		*value = random
+	}
+	claims := oidcLoginClaims{
+		OIDCLogin: login,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    oidcLoginIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+	}
+	token, err := signClaims(claims)
+	if err != nil {
+		return OIDCLogin{}, "", err
+	}
+	return login, token, nil
+}
+
+// ValidateOIDCLogin returns the external sign in a token made by MakeOIDCLogin belongs to.
+func ValidateOIDCLogin(tokenString string) (OIDCLogin, error) {
+	claims := &oidcLoginClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithIssuer(oidcLoginIssuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+	return claims.OIDCLogin, nil
+}
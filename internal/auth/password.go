@@ -1,14 +1,232 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrPasswordMismatch = errors.New("password does not match hash")
+
+// PasswordHasher hashes passwords with one algorithm and recognises the hashes it produces.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) error
+	// Handles reports whether hash was produced by this algorithm.
+	Handles(hash string) bool
+	// NeedsRehash reports whether hash was produced with other parameters than the hasher's.
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher produces argon2id hashes in PHC string format:
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// BcryptHasher produces bcrypt hashes. bcrypt only looks at the first 72
+// bytes of a password, so it is kept for verifying existing hashes.
+type BcryptHasher struct {
+	Cost int
+}
+
+// DefaultArgon2idHasher uses the parameters recommended in RFC 9106 for memory constrained servers.
+func DefaultArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// CurrentHasher hashes new passwords. Hashes carry their own parameters, so
+// hashes from any algorithm in knownHashers still verify.
+var CurrentHasher PasswordHasher = DefaultArgon2idHasher()
+
+var knownHashers = []PasswordHasher{
+	DefaultArgon2idHasher(),
+	&BcryptHasher{Cost: bcrypt.DefaultCost},
+}
+
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return CurrentHasher.Hash(password)
 }
 
 func CheckPasswordHash(hash, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	for _, hasher := range knownHashers {
+		if hasher.Handles(hash) {
+			return hasher.Verify(hash, password)
+		}
+	}
+	return errors.New("unrecognised password hash format")
+}
+
+var dummyHash struct {
+	once sync.Once
+	hash string
+}
+
+// CheckDummyPassword takes as long as checking a password against a real
+// hash, so logins for unknown accounts cannot be told apart by timing.
+func CheckDummyPassword(password string) {
+	dummyHash.once.Do(func() {
+		dummyHash.hash, _ = HashPassword("chirpy-dummy-password")
+	})
+	CheckPasswordHash(dummyHash.hash, password)
+}
+
+// PasswordNeedsRehash reports whether a stored hash should be replaced with
+// one from CurrentHasher the next time the plain password is available.
+func PasswordNeedsRehash(hash string) bool {
+	return !CurrentHasher.Handles(hash) || CurrentHasher.NeedsRehash(hash)
+}
+
+// ConfigurePasswordHasherFromEnv sets CurrentHasher from PASSWORD_HASHER
+// ("argon2id", the default, or "bcrypt") and the ARGON2_MEMORY_KIB,
+// ARGON2_ITERATIONS, ARGON2_PARALLELISM or BCRYPT_COST parameters.
+func ConfigurePasswordHasherFromEnv() error {
+	switch os.Getenv("PASSWORD_HASHER") {
+	case "", "argon2id":
+		hasher := DefaultArgon2idHasher()
+		err := envUint("ARGON2_MEMORY_KIB", 32, func(v uint64) { hasher.Memory = uint32(v) })
+		if err != nil {
+			return err
+		}
+		err = envUint("ARGON2_ITERATIONS", 32, func(v uint64) { hasher.Iterations = uint32(v) })
+		if err != nil {
+			return err
+		}
+		err = envUint("ARGON2_PARALLELISM", 8, func(v uint64) { hasher.Parallelism = uint8(v) })
+		if err != nil {
+			return err
+		}
+		CurrentHasher = hasher
+	case "bcrypt":
+		hasher := &BcryptHasher{Cost: bcrypt.DefaultCost}
+		err := envUint("BCRYPT_COST", 8, func(v uint64) { hasher.Cost = int(v) })
+		if err != nil {
+			return err
+		}
+		CurrentHasher = hasher
+	default:
+		return fmt.Errorf("unknown PASSWORD_HASHER %q", os.Getenv("PASSWORD_HASHER"))
+	}
+	return nil
+}
+
+func envUint(name string, bits int, set func(uint64)) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, bits)
+	if err != nil || parsed == 0 {
+		return fmt.Errorf("%v must be a positive integer", name)
+	}
+	set(parsed)
+	return nil
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(hash, password string) error {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Memory || params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism || uint32(len(salt)) != h.SaltLength ||
+		uint32(len(key)) != h.KeyLength
+}
+
+// split a PHC argon2id string into its parameters, salt and derived key
+func parseArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	params := Argon2idHasher{}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	return params, salt, key, nil
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h *BcryptHasher) Verify(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+func (h *BcryptHasher) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
 }
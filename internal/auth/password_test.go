@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// small parameters so the tests stay fast
+func testArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func useHasher(t *testing.T, hasher PasswordHasher) {
+	oldHasher := CurrentHasher
+	CurrentHasher = hasher
+	t.Cleanup(func() { CurrentHasher = oldHasher })
+}
+
+func TestArgon2idHashAndCheck(t *testing.T) {
+	useHasher(t, testArgon2idHasher())
+
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("expected PHC formatted argon2id hash, got %v", hash)
+	}
+	if err := CheckPasswordHash(hash, "correct horse battery staple"); err != nil {
+		t.Fatalf("CheckPasswordHash failed: %v", err)
+	}
+	if err := CheckPasswordHash(hash, "wrong password"); err != ErrPasswordMismatch {
+		t.Fatalf("expected ErrPasswordMismatch, got %v", err)
+	}
+	if PasswordNeedsRehash(hash) {
+		t.Error("expected hash with current parameters not to need a rehash")
+	}
+}
+
+func TestArgon2idLongPasswords(t *testing.T) {
+	useHasher(t, testArgon2idHasher())
+
+	// bcrypt would ignore everything past the 72nd byte
+	prefix := strings.Repeat("a", 72)
+	hash, err := HashPassword(prefix + "first")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if err := CheckPasswordHash(hash, prefix+"second"); err == nil {
+		t.Fatal("expected passwords differing after 72 bytes not to match")
+	}
+}
+
+func TestBcryptHashesStillVerify(t *testing.T) {
+	useHasher(t, testArgon2idHasher())
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt failed: %v", err)
+	}
+	if err := CheckPasswordHash(string(legacy), "hunter2"); err != nil {
+		t.Fatalf("CheckPasswordHash failed for bcrypt hash: %v", err)
+	}
+	if err := CheckPasswordHash(string(legacy), "hunter3"); err != ErrPasswordMismatch {
+		t.Fatalf("expected ErrPasswordMismatch, got %v", err)
+	}
+	if !PasswordNeedsRehash(string(legacy)) {
+		t.Error("expected bcrypt hash to need a rehash to argon2id")
+	}
+}
+
+func TestArgon2idParameterChangeNeedsRehash(t *testing.T) {
+	useHasher(t, testArgon2idHasher())
+	hash, _ := HashPassword("password")
+
+	stronger := testArgon2idHasher()
+	stronger.Iterations = 2
+	useHasher(t, stronger)
+	if !PasswordNeedsRehash(hash) {
+		t.Error("expected hash with old parameters to need a rehash")
+	}
+	if err := CheckPasswordHash(hash, "password"); err != nil {
+		t.Fatalf("expected hash with old parameters to still verify, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID uuid.UUID
+	// SessionID is uuid.Nil for credentials not tied to a login session
+	SessionID uuid.UUID
+	TokenID   string
+	ExpiresAt time.Time
+	Role      string
+	// Scopes limit what the caller may do; nil grants everything the user can do
+	Scopes []string
+	// ClientID is the OAuth client acting for the user, uuid.Nil for first-party credentials
+	ClientID uuid.UUID
+	// AuthTime is when the user last signed in with their credentials, zero when unknown
+	AuthTime time.Time
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PrincipalFromClaims builds the principal for a validated access token.
+func PrincipalFromClaims(claims *Claims) (*Principal, error) {
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	// tokens issued before sessions were tracked have no sid
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	principal := &Principal{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenID:   claims.ID,
+		Role:      claims.Role,
+	}
+	if principal.Role == "" {
+		// tokens issued before roles were added
+		principal.Role = RoleUser
+	}
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if claims.AuthTime != nil {
+		principal.AuthTime = claims.AuthTime.Time
+	}
+	if claims.ClientID != "" {
+		principal.ClientID, err = uuid.Parse(claims.ClientID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if claims.Scope != "" || claims.ClientID != "" {
+		// a client token with no scope claim is granted nothing rather than everything
+		principal.Scopes = strings.Fields(claims.Scope)
+	}
+	return principal, nil
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
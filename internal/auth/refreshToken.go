@@ -7,11 +7,19 @@ import (
 )
 
 func MakeRefreshToken() (string, error) {
-	refreshTokenBytes := make([]byte, 32)
-	_, err := rand.Read(refreshTokenBytes)
+	refreshToken, err := MakeOpaqueToken()
 	if err != nil {
 		return "", fmt.Errorf("error generating refresh token: %w", err)
 	}
-	refreshToken := hex.EncodeToString(refreshTokenBytes)
 	return refreshToken, nil
 }
+
+// MakeOpaqueToken returns 32 random bytes, hex encoded, for single-use links and codes.
+func MakeOpaqueToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
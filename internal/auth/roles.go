@@ -0,0 +1,25 @@
+package auth
+
+// Roles, from least to most privileged. Each role can do everything the roles before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether the principal's role is role or a more privileged one.
+func (p *Principal) HasRole(role string) bool {
+	return roleRank[p.Role] >= roleRank[role] && ValidRole(role)
+}
@@ -0,0 +1,40 @@
+package auth
+
+import "strings"
+
+// Scopes that delegated credentials such as personal access tokens can be limited to.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeAccountRead  = "account:read"
+	ScopeAccountWrite = "account:write"
+)
+
+var knownScopes = map[string]bool{
+	ScopeChirpsRead:   true,
+	ScopeChirpsWrite:  true,
+	ScopeAccountRead:  true,
+	ScopeAccountWrite: true,
+}
+
+// ValidScope reports whether scope is one of the known scopes.
+func ValidScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+// PersonalAccessTokenPrefix starts every personal access token, so leaked
+// tokens are easy to recognise and scan for.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether token looks like a personal access token.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// SendLimiter allows something, such as emailing an address, at most Limit
+// times per key in any Window.
+type SendLimiter struct {
+	Limit  int
+	Window time.Duration
+	Now    func() time.Time
+
+	mu    sync.Mutex
+	sends map[string][]time.Time
+}
+
+func NewSendLimiter(limit int, window time.Duration) *SendLimiter {
+	return &SendLimiter{
+		Limit:  limit,
+		Window: window,
+		Now:    time.Now,
+		sends:  map[string][]time.Time{},
+	}
+}
+
+// Check reports whether a send for key is under the limit, and if not how long until
+// it will be. It does not count as a send; call Record once the send goes ahead.
+func (l *SendLimiter) Check(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	recent := l.recent(key, now)
+	l.sends[key] = recent
+	if len(recent) >= l.Limit {
+		return recent[0].Add(l.Window).Sub(now), false
+	}
+	return 0, true
+}
+
+// Record counts a send for key.
+func (l *SendLimiter) Record(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	l.sends[key] = append(l.recent(key, now), now)
+}
+
+// Prune drops keys with no sends inside Window.
+func (l *SendLimiter) Prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	for key := range l.sends {
+		if recent := l.recent(key, now); len(recent) == 0 {
+			delete(l.sends, key)
+		} else {
+			l.sends[key] = recent
+		}
+	}
+}
+
+// the sends for key still inside Window, oldest first
+func (l *SendLimiter) recent(key string, now time.Time) []time.Time {
+	sends := l.sends[key]
+	for len(sends) > 0 && now.Sub(sends[0]) >= l.Window {
+		sends = sends[1:]
+	}
+	return sends
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// LoginThrottle tracks failed login attempts per key (an account or a client
+// address) and makes the key wait, exponentially longer after each failure
+// past FreeAttempts, until it is locked out for LockoutDuration.
+type LoginThrottle struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// failures older than Window are forgotten
+	Window time.Duration
+	Now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*loginAttempts
+}
+
+type loginAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func NewLoginThrottle(freeAttempts, lockoutThreshold int, lockoutDuration time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		FreeAttempts:     freeAttempts,
+		BaseDelay:        time.Second,
+		LockoutThreshold: lockoutThreshold,
+		LockoutDuration:  lockoutDuration,
+		Window:           time.Hour,
+		Now:              time.Now,
+		entries:          map[string]*loginAttempts{},
+	}
+}
+
+// Check reports whether key may attempt a login now, and if not how long it has to wait.
+func (t *LoginThrottle) Check(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries[key]
+	if !ok {
+		return 0, true
+	}
+	wait := entry.blockedUntil.Sub(t.Now())
+	if wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// Failure records a failed attempt for key.
+func (t *LoginThrottle) Failure(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	entry, ok := t.entries[key]
+	if !ok || now.Sub(entry.lastFailure) > t.Window {
+		entry = &loginAttempts{}
+		t.entries[key] = entry
+	}
+	entry.failures++
+	entry.lastFailure = now
+	switch {
+	case entry.failures >= t.LockoutThreshold:
+		entry.blockedUntil = now.Add(t.LockoutDuration)
+	case entry.failures > t.FreeAttempts:
+		entry.blockedUntil = now.Add(t.backoff(entry.failures - t.FreeAttempts - 1))
+	}
+}
+
+// BaseDelay doubled the given number of times, capped at LockoutDuration. The cap
+// is checked before shifting, since a large shift overflows into a negative delay.
+func (t *LoginThrottle) backoff(doublings int) time.Duration {
+	if t.BaseDelay <= 0 {
+		return 0
+	}
+	if doublings >= bits.Len64(uint64(t.LockoutDuration/t.BaseDelay)) {
+		return t.LockoutDuration
+	}
+	return t.BaseDelay << doublings
+}
+
+// Success forgets earlier failures for key.
+func (t *LoginThrottle) Success(key string) {
+	t.Clear(key)
+}
+
+// Clear lifts any delay or lockout on key, reporting whether there was anything to clear.
+func (t *LoginThrottle) Clear(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.entries[key]
+	delete(t.entries, key)
+	return ok
+}
+
+// Prune drops keys that are no longer blocked and whose failures have been forgotten.
+func (t *LoginThrottle) Prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	for key, entry := range t.entries {
+		if now.After(entry.blockedUntil) && now.Sub(entry.lastFailure) > t.Window {
+			delete(t.entries, key)
+		}
+	}
+}
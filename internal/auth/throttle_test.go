@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestThrottle(now *time.Time) *LoginThrottle {
+	throttle := NewLoginThrottle(3, 6, 15*time.Minute)
+	throttle.Now = func() time.Time { return *now }
+	return throttle
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	throttle := newTestThrottle(&now)
+
+	for i := 0; i < 3; i++ {
+		throttle.Failure("account:user@example.com")
+		if _, ok := throttle.Check("account:user@example.com"); !ok {
+			t.Fatalf("expected no delay after %v failures", i+1)
+		}
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second}
+	for _, delay := range expected {
+		throttle.Failure("account:user@example.com")
+		wait, ok := throttle.Check("account:user@example.com")
+		if ok || wait != delay {
+			t.Fatalf("expected to wait %v, got %v (allowed: %v)", delay, wait, ok)
+		}
+		now = now.Add(delay)
+		if _, ok := throttle.Check("account:user@example.com"); !ok {
+			t.Fatalf("expected attempts to be allowed again after %v", delay)
+		}
+	}
+
+	throttle.Failure("account:user@example.com")
+	wait, ok := throttle.Check("account:user@example.com")
+	if ok || wait != 15*time.Minute {
+		t.Fatalf("expected lockout of 15m, got %v (allowed: %v)", wait, ok)
+	}
+	if _, ok := throttle.Check("account:other@example.com"); !ok {
+		t.Fatal("expected other keys not to be affected")
+	}
+}
+
+// the per-address limits are far enough apart that an unchecked shift overflows
+func TestLoginThrottleLargeBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	throttle := NewLoginThrottle(10, 50, 15*time.Minute)
+	throttle.Now = func() time.Time { return now }
+
+	for failures := 1; failures <= 50; failures++ {
+		throttle.Failure("ip:192.0.2.1")
+		wait, ok := throttle.Check("ip:192.0.2.1")
+		if failures <= 10 {
+			if !ok {
+				t.Fatalf("expected no delay after %v failures", failures)
+			}
+			continue
+		}
+		if ok || wait <= 0 || wait > 15*time.Minute {
+			t.Fatalf("expected a delay of at most 15m after %v failures, got %v (allowed: %v)", failures, wait, ok)
+		}
+		if failures >= 21 && wait != 15*time.Minute {
+			t.Fatalf("expected the delay to be capped at 15m after %v failures, got %v", failures, wait)
+		}
+	}
+}
+
+func TestLoginThrottleClearAndSuccess(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	throttle := newTestThrottle(&now)
+	for i := 0; i < 6; i++ {
+		throttle.Failure("ip:192.0.2.1")
+	}
+	if _, ok := throttle.Check("ip:192.0.2.1"); ok {
+		t.Fatal("expected key to be locked out")
+	}
+	if !throttle.Clear("ip:192.0.2.1") {
+		t.Fatal("expected Clear to report a lockout was cleared")
+	}
+	if _, ok := throttle.Check("ip:192.0.2.1"); !ok {
+		t.Fatal("expected key to be allowed after Clear")
+	}
+
+	for i := 0; i < 4; i++ {
+		throttle.Failure("ip:192.0.2.1")
+	}
+	throttle.Success("ip:192.0.2.1")
+	throttle.Failure("ip:192.0.2.1")
+	if _, ok := throttle.Check("ip:192.0.2.1"); !ok {
+		t.Fatal("expected failures before a success to be forgotten")
+	}
+}
+
+func TestLoginThrottleWindowAndPrune(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	throttle := newTestThrottle(&now)
+	for i := 0; i < 4; i++ {
+		throttle.Failure("account:user@example.com")
+	}
+	now = now.Add(2 * time.Hour)
+	throttle.Prune()
+	if len(throttle.entries) != 0 {
+		t.Fatalf("expected stale entries to be pruned, %v left", len(throttle.entries))
+	}
+}
+
+func TestSendLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewSendLimiter(2, time.Minute)
+	limiter.Now = func() time.Time { return now }
+	send := func(key string) (time.Duration, bool) {
+		wait, ok := limiter.Check(key)
+		if ok {
+			limiter.Record(key)
+		}
+		return wait, ok
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := limiter.Check("user@example.com"); !ok {
+			t.Fatalf("expected checking before send %v not to count as a send", i+1)
+		}
+		if _, ok := send("user@example.com"); !ok {
+			t.Fatalf("expected send %v to be allowed", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	wait, ok := send("user@example.com")
+	if ok || wait != 40*time.Second {
+		t.Fatalf("expected to wait 40s for the oldest send to age out, got %v (allowed: %v)", wait, ok)
+	}
+	if _, ok := send("other@example.com"); !ok {
+		t.Error("expected other keys not to be limited")
+	}
+
+	now = now.Add(wait)
+	if _, ok := send("user@example.com"); !ok {
+		t.Error("expected a send once the oldest one aged out")
+	}
+	if _, ok := send("user@example.com"); ok {
+		t.Error("expected the limit to apply again")
+	}
+
+	now = now.Add(time.Minute)
+	limiter.Prune()
+	if len(limiter.sends) != 0 {
+		t.Errorf("expected every key to be pruned, got %v", limiter.sends)
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+var TokenHashKey string
+
+func init() {
+	godotenv.Load()
+	TokenHashKey = os.Getenv("TOKEN_HASH_KEY")
+}
+
+// HashToken returns the keyed hash under which an opaque token is stored. The
+// hash is deterministic so it can be used to look the token up.
+func HashToken(token string) string {
+	key := TokenHashKey
+	if key == "" {
+		key = TokenSecret
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LegacyTokenHash returns the unkeyed hash given to refresh tokens that were
+// stored in plain text before hashing was introduced.
+func LegacyTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vectors for SHA-1, truncated to six digits
+var totpVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCode(t *testing.T) {
+	for _, vector := range totpVectors {
+		code, err := TOTPCode(rfcSecret, time.Unix(vector.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if code != vector.code {
+			t.Errorf("at %v: expected %v, got %v", vector.unix, vector.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	fixedClock := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfcSecret, fixedClock)
+
+	step, ok := ValidateTOTP(rfcSecret, code, fixedClock)
+	if !ok || step != totpStep(fixedClock) {
+		t.Fatalf("expected code to validate at step %v, got %v %v", totpStep(fixedClock), step, ok)
+	}
+	if _, ok := ValidateTOTP(rfcSecret, code, fixedClock.Add(totpPeriod*time.Second)); !ok {
+		t.Error("expected code from the previous step to be accepted")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, code, fixedClock.Add(3*totpPeriod*time.Second)); ok {
+		t.Error("expected code from three steps ago to be rejected")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, "000000", fixedClock); ok {
+		t.Error("expected wrong code to be rejected")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected recovery code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))) != code {
+			t.Errorf("expected %q to normalize back to itself", code)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -23,6 +24,45 @@ func (q *Queries) ActivateChirpyRed(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const cancelAccountDeletion = `-- name: CancelAccountDeletion :execrows
+UPDATE users
+SET deletion_scheduled_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND deletion_scheduled_at IS NOT NULL
+`
+
+func (q *Queries) CancelAccountDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelAccountDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const confirmPendingEmail = `-- name: ConfirmPendingEmail :execrows
+UPDATE users
+SET email = pending_email,
+    pending_email = NULL,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND pending_email = $2
+`
+
+type ConfirmPendingEmailParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+func (q *Queries) ConfirmPendingEmail(ctx context.Context, arg ConfirmPendingEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, confirmPendingEmail, arg.ID, arg.PendingEmail)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -32,7 +72,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, totp_secret, totp_enabled_at, totp_last_step, tokens_valid_after, role, deletion_scheduled_at
 `
 
 type CreateUserParams struct {
@@ -50,6 +90,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
@@ -66,8 +114,64 @@ func (q *Queries) DeactivateChirpyRed(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteScheduledAccounts = `-- name: DeleteScheduledAccounts :execrows
+DELETE FROM users
+WHERE deletion_scheduled_at <= NOW()
+`
+
+func (q *Queries) DeleteScheduledAccounts(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledAccounts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL,
+    totp_enabled_at = NULL,
+    totp_last_step = 0,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(),
+    totp_last_step = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type EnableTOTPParams struct {
+	TotpLastStep int64
+	ID           uuid.UUID
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, arg.TotpLastStep, arg.ID)
+	return err
+}
+
+const getTokensValidAfter = `-- name: GetTokensValidAfter :one
+SELECT tokens_valid_after FROM users WHERE id = $1
+`
+
+func (q *Queries) GetTokensValidAfter(ctx context.Context, id uuid.UUID) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getTokensValidAfter, id)
+	var tokens_valid_after sql.NullTime
+	err := row.Scan(&tokens_valid_after)
+	return tokens_valid_after, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, totp_secret, totp_enabled_at, totp_last_step, tokens_valid_after, role FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -80,12 +184,20 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, totp_secret, totp_enabled_at, totp_last_step, tokens_valid_after, role FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -98,10 +210,59 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.DeletionScheduledAt,
 	)
 	return i, err
 }
 
+const markEmailVerified = `-- name: MarkEmailVerified :execrows
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND email = $2
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordTOTPStep = `-- name: RecordTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $1
+WHERE id = $2
+  AND totp_last_step < $1
+`
+
+type RecordTOTPStepParams struct {
+	TotpLastStep int64
+	ID           uuid.UUID
+}
+
+func (q *Queries) RecordTOTPStep(ctx context.Context, arg RecordTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordTOTPStep, arg.TotpLastStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users
 `
@@ -111,21 +272,145 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
-const updateAccount = `-- name: UpdateAccount :exec
+const revokeTokensIssuedBefore = `-- name: RevokeTokensIssuedBefore :exec
+UPDATE users
+SET tokens_valid_after = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type RevokeTokensIssuedBeforeParams struct {
+	TokensValidAfter sql.NullTime
+	ID               uuid.UUID
+}
+
+func (q *Queries) RevokeTokensIssuedBefore(ctx context.Context, arg RevokeTokensIssuedBeforeParams) error {
+	_, err := q.db.ExecContext(ctx, revokeTokensIssuedBefore, arg.TokensValidAfter, arg.ID)
+	return err
+}
+
+const scheduleAccountDeletion = `-- name: ScheduleAccountDeletion :exec
+UPDATE users
+SET deletion_scheduled_at = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type ScheduleAccountDeletionParams struct {
+	DeletionScheduledAt sql.NullTime
+	ID                  uuid.UUID
+}
+
+func (q *Queries) ScheduleAccountDeletion(ctx context.Context, arg ScheduleAccountDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleAccountDeletion, arg.DeletionScheduledAt, arg.ID)
+	return err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :exec
 UPDATE users
-SET email = $1,
-    hashed_password = $2,
+SET pending_email = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type SetPendingEmailParams struct {
+	PendingEmail sql.NullString
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setPendingEmail, arg.PendingEmail, arg.ID)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :execrows
+UPDATE users
+SET role = $1,
+    updated_at = NOW()
+WHERE email = $2
+`
+
+type SetUserRoleParams struct {
+	Role  string
+	Email string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.Role, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $1,
+    totp_enabled_at = NULL,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type SetTOTPSecretParams struct {
+	TotpSecret sql.NullString
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const updateAccount = `-- name: UpdateAccount :one
+UPDATE users
+SET hashed_password = COALESCE($1, hashed_password),
+    pending_email = COALESCE($2, pending_email),
     updated_at = NOW()
 WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at, pending_email, totp_secret, totp_enabled_at, totp_last_step, tokens_valid_after, role, deletion_scheduled_at
 `
 
 type UpdateAccountParams struct {
-	Email          string
+	HashedPassword sql.NullString
+	PendingEmail   sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateAccount, arg.HashedPassword, arg.PendingEmail, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TokensValidAfter,
+		&i.Role,
+		&i.DeletionScheduledAt,
+	)
+	return i, err
+}
+
+const updatePassword = `-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdatePasswordParams struct {
 	HashedPassword string
 	ID             uuid.UUID
 }
 
-func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) error {
-	_, err := q.db.ExecContext(ctx, updateAccount, arg.Email, arg.HashedPassword, arg.ID)
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.HashedPassword, arg.ID)
 	return err
 }
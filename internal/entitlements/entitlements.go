@@ -0,0 +1,122 @@
+// Package entitlements decides what each plan lets a user do.
+//
+// Every limit lives here rather than in the handlers that enforce it, and the
+// built-in tiers can be overridden from a JSON file so they can be tuned
+// without a code change.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Plans a user can be on.
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// Limits are what a plan allows. A zero count means the feature is unavailable,
+// except for ChirpsPerHour where zero means unlimited.
+type Limits struct {
+	MaxChirpLength      int      `json:"max_chirp_length"`
+	ChirpsPerHour       int      `json:"chirps_per_hour"`
+	EditWindow          Duration `json:"edit_window"`
+	MaxMediaAttachments int      `json:"max_media_attachments"`
+	ScheduledPosts      bool     `json:"scheduled_posts"`
+}
+
+// Duration is a time.Duration written in JSON as a string such as "15m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Engine maps plans to their limits.
+type Engine struct {
+	plans map[string]Limits
+}
+
+// Defaults returns the built-in tiers.
+func Defaults() *Engine {
+	return &Engine{plans: map[string]Limits{
+		PlanFree: {
+			MaxChirpLength: 140,
+			ChirpsPerHour:  30,
+		},
+		PlanChirpyRed: {
+			MaxChirpLength:      500,
+			ChirpsPerHour:       0,
+			EditWindow:          Duration(15 * time.Minute),
+			MaxMediaAttachments: 4,
+			ScheduledPosts:      true,
+		},
+	}}
+}
+
+// Load reads plan limits from JSON, keyed by plan name. Fields left out keep their
+// default for that plan, and plans not in the defaults start from the free tier.
+func Load(r io.Reader) (*Engine, error) {
+	overrides := map[string]json.RawMessage{}
+	err := json.NewDecoder(r).Decode(&overrides)
+	if err != nil {
+		return nil, err
+	}
+	engine := Defaults()
+	for plan, raw := range overrides {
+		limits, ok := engine.plans[plan]
+		if !ok {
+			limits = engine.plans[PlanFree]
+		}
+		err = json.Unmarshal(raw, &limits)
+		if err != nil {
+			return nil, fmt.Errorf("plan %v: %w", plan, err)
+		}
+		if limits.MaxChirpLength < 0 || limits.ChirpsPerHour < 0 || limits.EditWindow < 0 || limits.MaxMediaAttachments < 0 {
+			return nil, fmt.Errorf("plan %v: limits cannot be negative", plan)
+		}
+		engine.plans[plan] = limits
+	}
+	return engine, nil
+}
+
+// FromEnv loads the file named by ENTITLEMENTS_FILE, or the defaults when it is not set.
+func FromEnv() (*Engine, error) {
+	path := os.Getenv("ENTITLEMENTS_FILE")
+	if path == "" {
+		return Defaults(), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// For returns the limits of plan, falling back to the free tier for unknown plans.
+func (e *Engine) For(plan string) Limits {
+	limits, ok := e.plans[plan]
+	if !ok {
+		return e.plans[PlanFree]
+	}
+	return limits
+}
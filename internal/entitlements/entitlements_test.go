@@ -0,0 +1,70 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	engine := Defaults()
+	free, red := engine.For(PlanFree), engine.For(PlanChirpyRed)
+	if free.MaxChirpLength != 140 {
+		t.Errorf("expected free chirps to stay at 140 characters, got %v", free.MaxChirpLength)
+	}
+	if red.MaxChirpLength <= free.MaxChirpLength || !red.ScheduledPosts || free.ScheduledPosts {
+		t.Errorf("expected Chirpy Red to allow more than free, got %+v and %+v", red, free)
+	}
+	if unknown := engine.For("platinum"); unknown != free {
+		t.Errorf("expected an unknown plan to get the free tier, got %+v", unknown)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	engine, err := Load(strings.NewReader(`{
+		"free": {"chirps_per_hour": 5},
+		"chirpy_red": {"edit_window": "1h", "scheduled_posts": false},
+		"staff": {"max_chirp_length": 1000}
+	}`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	free := engine.For(PlanFree)
+	if free.ChirpsPerHour != 5 || free.MaxChirpLength != 140 {
+		t.Errorf("expected only the rate to change on free, got %+v", free)
+	}
+	red := engine.For(PlanChirpyRed)
+	if time.Duration(red.EditWindow) != time.Hour || red.ScheduledPosts || red.MaxChirpLength != 500 {
+		t.Errorf("unexpected Chirpy Red limits %+v", red)
+	}
+	staff := engine.For("staff")
+	if staff.MaxChirpLength != 1000 || staff.ChirpsPerHour != free.ChirpsPerHour {
+		t.Errorf("expected a new plan to start from the free tier, got %+v", staff)
+	}
+	if Defaults().For(PlanFree).ChirpsPerHour == 5 {
+		t.Error("expected overrides not to leak into the defaults")
+	}
+}
+
+func TestLoadRejectsInvalid(t *testing.T) {
+	for _, config := range []string{
+		`{"free": {"edit_window": "forever"}}`,
+		`{"free": {"max_chirp_length": -1}}`,
+		`["free"]`,
+	} {
+		if _, err := Load(strings.NewReader(config)); err == nil {
+			t.Errorf("expected %v to be rejected", config)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	data, err := json.Marshal(Limits{EditWindow: Duration(90 * time.Second)})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"edit_window":"1m30s"`) {
+		t.Errorf("expected the edit window as a duration string, got %s", data)
+	}
+}
@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fake SMTP server that accepts a single message and returns its DATA section
+func startSMTPStandIn(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				inData = true
+				reply("354 End data with <CR><LF>.<CR><LF>")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := startSMTPStandIn(t)
+	mailer := &SMTPMailer{Addr: addr, From: "chirpy@example.com"}
+	err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	data := <-received
+	for _, want := range []string{"From: chirpy@example.com", "To: user@example.com", "Subject: Hello", "line one\r\nline two"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("expected message to contain %q, got:\n%v", want, data)
+		}
+	}
+}
+
+func TestSMTPMailerCancelled(t *testing.T) {
+	// a server that accepts the connection and then never says anything
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	mailer := &SMTPMailer{Addr: listener.Addr().String(), From: "chirpy@example.com"}
+	start := time.Now()
+	err = mailer.Send(ctx, Message{To: "user@example.com", Subject: "Hello", Body: "body"})
+	if err == nil {
+		t.Fatal("expected Send to fail against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected Send to give up when the context ended, took %v", elapsed)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := &FileMailer{Dir: dir}
+	err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Hello", Body: "body"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 message file, got %v", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: user@example.com") {
+		t.Errorf("unexpected message file contents:\n%s", data)
+	}
+}
@@ -0,0 +1,312 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// how often an unknown kid may trigger a JWKS refetch, so forged tokens cannot hammer the provider
+const jwksRefreshInterval = time.Minute
+
+// accepted ID token algorithms; HMAC is never accepted since a provider's keys are public
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config identifies Chirpy to an OpenID Connect provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested in addition to openid
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the provider's discovery document the relying party uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type idTokenClaims struct {
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email,omitempty"`
+	// some providers send the boolean as a string
+	EmailVerified interface{} `json:"email_verified,omitempty"`
+	Name          string      `json:"name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Provider is an OpenID Connect provider users can sign in with. Discovery
+// happens on first use and is retried on later requests until it succeeds.
+type Provider struct {
+	config Config
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          *auth.Keyring
+	keysFetchedAt time.Time
+}
+
+func NewProvider(config Config) *Provider {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{config: config}
+}
+
+// FromEnv configures a provider from OIDC_ISSUER, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL, defaulting the redirect to
+// defaultRedirectURL. It returns nil when OIDC_ISSUER is not set.
+func FromEnv(defaultRedirectURL string) (*Provider, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	config := Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       []string{"email", "profile"},
+	}
+	if config.ClientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID must be set when OIDC_ISSUER is")
+	}
+	if config.RedirectURL == "" {
+		config.RedirectURL = defaultRedirectURL
+	}
+	return NewProvider(config), nil
+}
+
+// Issuer is the provider's issuer identifier, which scopes its subject identifiers.
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// Discover fetches the provider's discovery document if it has not been
+// fetched yet.
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	metadata := &Metadata{}
+	err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", metadata)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	// OpenID Connect Discovery section 4.3
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match configured issuer %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery: document is missing required endpoints")
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// AuthCodeURL is where to send the user to sign in. state protects the
+// callback from cross-site requests, nonce binds the ID token to this login
+// and codeChallenge is the S256 PKCE challenge for the code exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, p.config.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token
+// issued with it.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	response, err := p.config.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %v: %s", response.StatusCode, body)
+	}
+	tokens := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	err = json.Unmarshal(body, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// VerifyIDToken checks an ID token's signature against the provider's JWKS
+// and its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	if _, err := p.Discover(ctx); err != nil {
+		return nil, err
+	}
+	keyfunc := func(t *jwt.Token) (interface{}, error) {
+		return p.keyfunc(ctx, t)
+	}
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, keyfunc,
+		jwt.WithValidMethods(idTokenMethods),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+	// OpenID Connect Core section 3.1.3.7
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, errors.New("ID token was issued to another party")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	verified, _ := claims.EmailVerified.(bool)
+	if s, ok := claims.EmailVerified.(string); ok {
+		verified = s == "true"
+	}
+	return &IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// find the key an ID token was signed with, refetching the JWKS once when the
+// kid is unknown since the provider may have rotated its keys
+func (p *Provider) keyfunc(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	keys, err := p.signingKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keys.Keyfunc(t)
+	if err == nil {
+		return key, nil
+	}
+	keys, refreshErr := p.signingKeys(ctx, true)
+	if refreshErr != nil {
+		return nil, refreshErr
+	}
+	return keys.Keyfunc(t)
+}
+
+func (p *Provider) signingKeys(ctx context.Context, refresh bool) (*auth.Keyring, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && (!refresh || time.Since(p.keysFetchedAt) < jwksRefreshInterval) {
+		return p.keys, nil
+	}
+	set := auth.JWKSet{}
+	err := p.getJSON(ctx, p.metadata.JWKSURI, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	keys := auth.NewKeyring()
+	for _, jwk := range set.Keys {
+		key, err := auth.ParseJWK(jwk)
+		if err != nil {
+			// keys for other purposes or algorithms are skipped rather than failing every login
+			continue
+		}
+		keys.AddVerificationKey(key)
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.config.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v returned %v", url, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(v)
+}
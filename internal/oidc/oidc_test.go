@@ -0,0 +1,248 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// a stand-in identity provider serving discovery, a JWKS and a token endpoint
+type fakeIdP struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	keyring *auth.Keyring
+	private *rsa.PrivateKey
+	// authorization codes handed out by the test, with the PKCE challenge and nonce they were issued for
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	claims    idTokenClaims
+}
+
+const (
+	testClientID     = "chirpy"
+	testClientSecret = "s3cret/with+symbols"
+	testRedirectURL  = "http://localhost:8080/api/login/oidc/callback"
+)
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{codes: map[string]pendingCode{}}
+	idp.rotateKey(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode(idp.keyring.JWKS())
+	})
+	mux.HandleFunc("POST /token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if !ok || clientID != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	idp.mu.Lock()
+	pending, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+	if !ok || r.PostFormValue("redirect_uri") != testRedirectURL || !auth.VerifyPKCE(r.PostFormValue("code_verifier"), pending.challenge) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idp.sign(pending.claims)})
+}
+
+func (idp *fakeIdP) issueCode(code, challenge string, claims idTokenClaims) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.codes[code] = pendingCode{challenge: challenge, claims: claims}
+}
+
+func (idp *fakeIdP) rotateKey(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key failed: %v", err)
+	}
+	key, err := auth.NewSigningKey("", private)
+	if err != nil {
+		t.Fatalf("NewSigningKey failed: %v", err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keyring = auth.NewKeyring()
+	idp.keyring.SetSigningKey(key)
+	idp.private = private
+}
+
+func (idp *fakeIdP) sign(claims idTokenClaims) string {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	token, _ := idp.keyring.Sign(claims)
+	return token
+}
+
+// claims for a valid ID token for nonce
+func (idp *fakeIdP) claims(nonce string) idTokenClaims {
+	return idTokenClaims{
+		Nonce:         nonce,
+		Email:         "staff@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.server.URL,
+			Subject:   "employee-42",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+		},
+	}
+}
+
+func (idp *fakeIdP) provider() *Provider {
+	return NewProvider(Config{
+		Issuer:       idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"email"},
+	})
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", auth.S256CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("client_id") != testClientID || query.Get("scope") != "openid email" ||
+		query.Get("state") != "state-1" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization URL %v", authURL)
+	}
+
+	// the user signs in at the provider, which redirects back with a code
+	idp.issueCode("code-1", query.Get("code_challenge"), idp.claims(query.Get("nonce")))
+	idToken, err := provider.Exchange(ctx, "code-1", verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if idToken.Issuer != idp.server.URL || idToken.Subject != "employee-42" || idToken.Email != "staff@example.com" || !idToken.EmailVerified {
+		t.Errorf("unexpected ID token %+v", idToken)
+	}
+
+	idp.issueCode("code-2", query.Get("code_challenge"), idp.claims("nonce-1"))
+	if _, err := provider.Exchange(ctx, "code-2", "wrong-verifier-wrong-verifier-wrong-verifier", "nonce-1"); err == nil {
+		t.Error("expected the exchange to fail with the wrong PKCE verifier")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims("n")).SignedString([]byte("guess"))
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	foreignToken, _ := jwt.NewWithClaims(jwt.SigningMethodES256, idp.claims("n")).SignedString(ecKey)
+	tests := map[string]string{
+		"HMAC signed":   hmacToken,
+		"unknown key":   foreignToken,
+		"wrong nonce":   idp.sign(idp.claims("other")),
+		"wrong issuer":  idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.Issuer = "https://evil.example" })),
+		"wrong client":  idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.Audience = jwt.ClaimStrings{"someone-else"} })),
+		"expired":       idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) })),
+		"no expiry":     idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.ExpiresAt = nil })),
+		"other azp":     idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.Audience = jwt.ClaimStrings{testClientID, "other"} })),
+		"empty subject": idp.sign(withClaims(idp.claims("n"), func(c *idTokenClaims) { c.Subject = "" })),
+	}
+	for name, token := range tests {
+		if _, err := provider.VerifyIDToken(ctx, token, "n"); err == nil {
+			t.Errorf("%v: expected ID token to be rejected", name)
+		}
+	}
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Errorf("expected a valid ID token to verify: %v", err)
+	}
+}
+
+func TestProviderKeyRotation(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Fatalf("expected ID token to verify: %v", err)
+	}
+
+	idp.rotateKey(t)
+	// let the unknown kid trigger a refetch
+	provider.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	if _, err := provider.VerifyIDToken(ctx, idp.sign(idp.claims("n")), "n"); err != nil {
+		t.Errorf("expected ID token signed with the rotated key to verify: %v", err)
+	}
+}
+
+func TestVerifyIDTokenWithoutKid(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider()
+	idp.mu.Lock()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims("n")).SignedString(idp.private)
+	idp.mu.Unlock()
+	if err != nil {
+		t.Fatalf("signing ID token failed: %v", err)
+	}
+	// providers with a single key may leave the kid out, so any key for the algorithm will do
+	if _, err := provider.VerifyIDToken(context.Background(), token, "n"); err != nil {
+		t.Errorf("expected an ID token without a kid to verify: %v", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{Issuer: "https://impostor.example", AuthorizationEndpoint: "a", TokenEndpoint: "t", JWKSURI: "j"})
+	}))
+	defer server.Close()
+	provider := NewProvider(Config{Issuer: server.URL, ClientID: testClientID})
+	if _, err := provider.Discover(context.Background()); err == nil {
+		t.Error("expected discovery to fail when the document names another issuer")
+	}
+}
+
+func withClaims(claims idTokenClaims, change func(*idTokenClaims)) idTokenClaims {
+	change(&claims)
+	return claims
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Memory is a Store held in process. Each method mirrors its SQL query:
+// constraints fail with the same *pq.Error codes, deleting a row cascades like
+// the schema's foreign keys, and results come back in the same order. Missing
+// rows are reported as sql.ErrNoRows.
+//
+// JSON payloads are kept byte for byte rather than normalised the way JSONB is.
+type Memory struct {
+	// Now stands in for the database clock; it defaults to time.Now
+	Now func() time.Time
+
+	mu                   sync.RWMutex
+	users                []database.User
+	userIdentities       []database.UserIdentity
+	chirps               []database.Chirp
+	refreshTokens        []database.RefreshToken
+	deniedAccessTokens   []database.RevokedAccessToken
+	personalAccessTokens []database.PersonalAccessToken
+	recoveryTokens       []database.RecoveryToken
+	recoveryCodes        []database.TwoFactorRecoveryCode
+	magicLinks           []database.MagicLink
+	oauthClients         []database.OauthClient
+	authorizationCodes   []database.OauthAuthorizationCode
+	oauthConsents        []database.OauthConsent
+	subscriptions        []database.Subscription
+	webhookEvents        []database.WebhookEvent
+	webhookEndpoints     []database.WebhookEndpoint
+	webhookDeliveries    []database.WebhookDelivery
+}
+
+func NewMemory() *Memory {
+	return &Memory{Now: time.Now}
+}
+
+// the statement time, at the microsecond precision Postgres keeps
+func (m *Memory) now() time.Time {
+	return timestamp(m.Now())
+}
+
+// a time as it reads back from a TIMESTAMP column
+func timestamp(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func nullTimestamp(t sql.NullTime) sql.NullTime {
+	if !t.Valid {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: timestamp(t.Time), Valid: true}
+}
+
+func uniqueViolation(table, constraint string) error {
+	return &pq.Error{
+		Code:       "23505",
+		Message:    fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return &pq.Error{
+		Code:       "23503",
+		Message:    fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+func checkViolation(table, constraint string) error {
+	return &pq.Error{
+		Code:       "23514",
+		Message:    fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+// rows handed in or out are copied so callers never share slices with the store
+func cloneJSON(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	return slices.Clone(raw)
+}
+
+func (m *Memory) user(id uuid.UUID) *database.User {
+	for i := range m.users {
+		if m.users[i].ID == id {
+			return &m.users[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) oauthClient(id uuid.UUID) *database.OauthClient {
+	for i := range m.oauthClients {
+		if m.oauthClients[i].ID == id {
+			return &m.oauthClients[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) webhookEndpoint(id uuid.UUID) *database.WebhookEndpoint {
+	for i := range m.webhookEndpoints {
+		if m.webhookEndpoints[i].ID == id {
+			return &m.webhookEndpoints[i]
+		}
+	}
+	return nil
+}
+
+// delete the matching users along with every row that references them (ON DELETE CASCADE)
+func (m *Memory) deleteUsers(match func(database.User) bool) int64 {
+	deleted := map[uuid.UUID]bool{}
+	m.users = slices.DeleteFunc(m.users, func(user database.User) bool {
+		if match(user) {
+			deleted[user.ID] = true
+			return true
+		}
+		return false
+	})
+	if len(deleted) == 0 {
+		return 0
+	}
+	m.deleteOAuthClients(func(client database.OauthClient) bool { return deleted[client.UserID] })
+	m.deleteWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool { return deleted[endpoint.UserID] })
+	m.userIdentities = slices.DeleteFunc(m.userIdentities, func(identity database.UserIdentity) bool { return deleted[identity.UserID] })
+	m.chirps = slices.DeleteFunc(m.chirps, func(chirp database.Chirp) bool { return deleted[chirp.UserID] })
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(token database.RefreshToken) bool { return deleted[token.UserID] })
+	m.deniedAccessTokens = slices.DeleteFunc(m.deniedAccessTokens, func(token database.RevokedAccessToken) bool { return deleted[token.UserID] })
+	m.personalAccessTokens = slices.DeleteFunc(m.personalAccessTokens, func(token database.PersonalAccessToken) bool { return deleted[token.UserID] })
+	m.recoveryTokens = slices.DeleteFunc(m.recoveryTokens, func(token database.RecoveryToken) bool { return deleted[token.UserID] })
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(code database.TwoFactorRecoveryCode) bool { return deleted[code.UserID] })
+	m.magicLinks = slices.DeleteFunc(m.magicLinks, func(link database.MagicLink) bool { return deleted[link.UserID] })
+	m.authorizationCodes = slices.DeleteFunc(m.authorizationCodes, func(code database.OauthAuthorizationCode) bool { return deleted[code.UserID] })
+	m.oauthConsents = slices.DeleteFunc(m.oauthConsents, func(consent database.OauthConsent) bool { return deleted[consent.UserID] })
+	m.subscriptions = slices.DeleteFunc(m.subscriptions, func(subscription database.Subscription) bool { return deleted[subscription.UserID] })
+	return int64(len(deleted))
+}
+
+// delete the matching OAuth clients with their codes, consents and refresh tokens
+func (m *Memory) deleteOAuthClients(match func(database.OauthClient) bool) int64 {
+	deleted := map[uuid.UUID]bool{}
+	m.oauthClients = slices.DeleteFunc(m.oauthClients, func(client database.OauthClient) bool {
+		if match(client) {
+			deleted[client.ID] = true
+			return true
+		}
+		return false
+	})
+	if len(deleted) == 0 {
+		return 0
+	}
+	m.authorizationCodes = slices.DeleteFunc(m.authorizationCodes, func(code database.OauthAuthorizationCode) bool { return deleted[code.ClientID] })
+	m.oauthConsents = slices.DeleteFunc(m.oauthConsents, func(consent database.OauthConsent) bool { return deleted[consent.ClientID] })
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(token database.RefreshToken) bool {
+		return token.ClientID.Valid && deleted[token.ClientID.UUID]
+	})
+	return int64(len(deleted))
+}
+
+// delete the matching webhook endpoints with their deliveries
+func (m *Memory) deleteWebhookEndpoints(match func(database.WebhookEndpoint) bool) int64 {
+	deleted := map[uuid.UUID]bool{}
+	m.webhookEndpoints = slices.DeleteFunc(m.webhookEndpoints, func(endpoint database.WebhookEndpoint) bool {
+		if match(endpoint) {
+			deleted[endpoint.ID] = true
+			return true
+		}
+		return false
+	})
+	if len(deleted) == 0 {
+		return 0
+	}
+	m.webhookDeliveries = slices.DeleteFunc(m.webhookDeliveries, func(delivery database.WebhookDelivery) bool { return deleted[delivery.EndpointID] })
+	return int64(len(deleted))
+}
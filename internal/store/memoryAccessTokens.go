@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+)
+
+func (m *Memory) DenyAccessToken(ctx context.Context, arg database.DenyAccessTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.deniedAccessTokens {
+		if token.Jti == arg.Jti {
+			return nil
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("revoked_access_tokens", "revoked_access_tokens_user_id_fkey")
+	}
+	m.deniedAccessTokens = append(m.deniedAccessTokens, database.RevokedAccessToken{
+		Jti:       arg.Jti,
+		UserID:    arg.UserID,
+		ExpiresAt: timestamp(arg.ExpiresAt),
+		RevokedAt: m.now(),
+	})
+	return nil
+}
+
+func (m *Memory) ListDeniedAccessTokens(ctx context.Context) ([]database.ListDeniedAccessTokensRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	var rows []database.ListDeniedAccessTokensRow
+	for _, token := range m.deniedAccessTokens {
+		if token.ExpiresAt.After(now) {
+			rows = append(rows, database.ListDeniedAccessTokensRow{Jti: token.Jti, ExpiresAt: token.ExpiresAt})
+		}
+	}
+	return rows, nil
+}
+
+func (m *Memory) PruneDeniedAccessTokens(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	before := len(m.deniedAccessTokens)
+	m.deniedAccessTokens = slices.DeleteFunc(m.deniedAccessTokens, func(token database.RevokedAccessToken) bool {
+		return !token.ExpiresAt.After(now)
+	})
+	return int64(before - len(m.deniedAccessTokens)), nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CountChirpsSince(ctx context.Context, arg database.CountChirpsSinceParams) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int64
+	for _, chirp := range m.chirps {
+		if chirp.UserID == arg.UserID && chirp.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(arg.UserID) == nil {
+		return database.Chirp{}, foreignKeyViolation("chirps", "chirps_user_id_fkey")
+	}
+	now := m.now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chirps = slices.DeleteFunc(m.chirps, func(chirp database.Chirp) bool { return chirp.ID == id })
+	return nil
+}
+
+func (m *Memory) GetAllChirps(ctx context.Context) ([]database.Chirp, error) {
+	return m.listChirps(func(database.Chirp) bool { return true }, false), nil
+}
+
+func (m *Memory) GetAllChirpsDesc(ctx context.Context) ([]database.Chirp, error) {
+	return m.listChirps(func(database.Chirp) bool { return true }, true), nil
+}
+
+func (m *Memory) GetChirpsByID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return m.listChirps(func(chirp database.Chirp) bool { return chirp.UserID == userID }, false), nil
+}
+
+func (m *Memory) GetChirpsByIDDesc(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return m.listChirps(func(chirp database.Chirp) bool { return chirp.UserID == userID }, true), nil
+}
+
+func (m *Memory) SelectSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, chirp := range m.chirps {
+		if chirp.ID == id {
+			return chirp, nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+// matching chirps ordered by created_at, oldest first unless desc
+func (m *Memory) listChirps(match func(database.Chirp) bool, desc bool) []database.Chirp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var chirps []database.Chirp
+	for _, chirp := range m.chirps {
+		if match(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		if desc {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return chirps
+}
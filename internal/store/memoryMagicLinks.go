@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreateMagicLink(ctx context.Context, arg database.CreateMagicLinkParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, link := range m.magicLinks {
+		if link.TokenHash == arg.TokenHash {
+			return uniqueViolation("magic_links", "magic_links_token_hash_key")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("magic_links", "magic_links_user_id_fkey")
+	}
+	m.magicLinks = append(m.magicLinks, database.MagicLink{
+		ID:        uuid.New(),
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		Email:     arg.Email,
+		CreatedAt: m.now(),
+		ExpiresAt: timestamp(arg.ExpiresAt),
+	})
+	return nil
+}
+
+func (m *Memory) InvalidateMagicLinks(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.magicLinks {
+		link := &m.magicLinks[i]
+		if link.UserID == userID && !link.UsedAt.Valid {
+			link.UsedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) UseMagicLink(ctx context.Context, tokenHash string) (database.MagicLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.magicLinks {
+		link := &m.magicLinks[i]
+		if link.TokenHash == tokenHash && !link.UsedAt.Valid && link.ExpiresAt.After(now) {
+			link.UsedAt = sql.NullTime{Time: now, Valid: true}
+			return *link, nil
+		}
+	}
+	return database.MagicLink{}, sql.ErrNoRows
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreateAuthorizationCode(ctx context.Context, arg database.CreateAuthorizationCodeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.authorizationCodes {
+		if code.CodeHash == arg.CodeHash {
+			return uniqueViolation("oauth_authorization_codes", "oauth_authorization_codes_code_hash_key")
+		}
+	}
+	if m.oauthClient(arg.ClientID) == nil {
+		return foreignKeyViolation("oauth_authorization_codes", "oauth_authorization_codes_client_id_fkey")
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("oauth_authorization_codes", "oauth_authorization_codes_user_id_fkey")
+	}
+	m.authorizationCodes = append(m.authorizationCodes, database.OauthAuthorizationCode{
+		ID:            uuid.New(),
+		CodeHash:      arg.CodeHash,
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        slices.Clone(arg.Scopes),
+		CodeChallenge: arg.CodeChallenge,
+		CreatedAt:     m.now(),
+		ExpiresAt:     timestamp(arg.ExpiresAt),
+	})
+	return nil
+}
+
+func (m *Memory) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(arg.UserID) == nil {
+		return database.OauthClient{}, foreignKeyViolation("oauth_clients", "oauth_clients_user_id_fkey")
+	}
+	now := m.now()
+	client := database.OauthClient{
+		ID:           uuid.New(),
+		UserID:       arg.UserID,
+		Name:         arg.Name,
+		SecretHash:   arg.SecretHash,
+		RedirectUris: slices.Clone(arg.RedirectUris),
+		Scopes:       slices.Clone(arg.Scopes),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	m.oauthClients = append(m.oauthClients, client)
+	return cloneOAuthClient(client), nil
+}
+
+func (m *Memory) DeleteOAuthClient(ctx context.Context, arg database.DeleteOAuthClientParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteOAuthClients(func(client database.OauthClient) bool {
+		return client.ID == arg.ID && client.UserID == arg.UserID
+	}), nil
+}
+
+func (m *Memory) DeleteOAuthConsent(ctx context.Context, arg database.DeleteOAuthConsentParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := len(m.oauthConsents)
+	m.oauthConsents = slices.DeleteFunc(m.oauthConsents, func(consent database.OauthConsent) bool {
+		return consent.UserID == arg.UserID && consent.ClientID == arg.ClientID
+	})
+	return int64(before - len(m.oauthConsents)), nil
+}
+
+func (m *Memory) GetAuthorizationCode(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, code := range m.authorizationCodes {
+		if code.CodeHash == codeHash {
+			code.Scopes = slices.Clone(code.Scopes)
+			return code, nil
+		}
+	}
+	return database.OauthAuthorizationCode{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client := m.oauthClient(id)
+	if client == nil {
+		return database.OauthClient{}, sql.ErrNoRows
+	}
+	return cloneOAuthClient(*client), nil
+}
+
+func (m *Memory) GetOAuthConsent(ctx context.Context, arg database.GetOAuthConsentParams) (database.OauthConsent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, consent := range m.oauthConsents {
+		if consent.UserID == arg.UserID && consent.ClientID == arg.ClientID {
+			consent.Scopes = slices.Clone(consent.Scopes)
+			return consent, nil
+		}
+	}
+	return database.OauthConsent{}, sql.ErrNoRows
+}
+
+func (m *Memory) InvalidateAuthorizationCodes(ctx context.Context, arg database.InvalidateAuthorizationCodesParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.authorizationCodes {
+		code := &m.authorizationCodes[i]
+		if code.UserID == arg.UserID && code.ClientID == arg.ClientID && !code.UsedAt.Valid {
+			code.UsedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ListOAuthClients(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var clients []database.OauthClient
+	for _, client := range m.oauthClients {
+		if client.UserID == userID {
+			clients = append(clients, cloneOAuthClient(client))
+		}
+	}
+	slices.SortStableFunc(clients, func(a, b database.OauthClient) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return clients, nil
+}
+
+func (m *Memory) ListOAuthConsents(ctx context.Context, userID uuid.UUID) ([]database.ListOAuthConsentsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var rows []database.ListOAuthConsentsRow
+	for _, consent := range m.oauthConsents {
+		if consent.UserID != userID {
+			continue
+		}
+		client := m.oauthClient(consent.ClientID)
+		if client == nil {
+			continue
+		}
+		rows = append(rows, database.ListOAuthConsentsRow{
+			ClientID:  consent.ClientID,
+			Name:      client.Name,
+			Scopes:    slices.Clone(consent.Scopes),
+			CreatedAt: consent.CreatedAt,
+			UpdatedAt: consent.UpdatedAt,
+		})
+	}
+	slices.SortStableFunc(rows, func(a, b database.ListOAuthConsentsRow) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return rows, nil
+}
+
+func (m *Memory) RedeemAuthorizationCode(ctx context.Context, arg database.RedeemAuthorizationCodeParams) (database.OauthAuthorizationCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.authorizationCodes {
+		code := &m.authorizationCodes[i]
+		if code.CodeHash == arg.CodeHash && !code.UsedAt.Valid {
+			code.UsedAt = sql.NullTime{Time: m.now(), Valid: true}
+			code.FamilyID = arg.FamilyID
+			redeemed := *code
+			redeemed.Scopes = slices.Clone(code.Scopes)
+			return redeemed, nil
+		}
+	}
+	return database.OauthAuthorizationCode{}, sql.ErrNoRows
+}
+
+func (m *Memory) UpsertOAuthConsent(ctx context.Context, arg database.UpsertOAuthConsentParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("oauth_consents", "oauth_consents_user_id_fkey")
+	}
+	if m.oauthClient(arg.ClientID) == nil {
+		return foreignKeyViolation("oauth_consents", "oauth_consents_client_id_fkey")
+	}
+	now := m.now()
+	for i := range m.oauthConsents {
+		consent := &m.oauthConsents[i]
+		if consent.UserID == arg.UserID && consent.ClientID == arg.ClientID {
+			consent.Scopes = slices.Clone(arg.Scopes)
+			consent.UpdatedAt = now
+			return nil
+		}
+	}
+	m.oauthConsents = append(m.oauthConsents, database.OauthConsent{
+		UserID:    arg.UserID,
+		ClientID:  arg.ClientID,
+		Scopes:    slices.Clone(arg.Scopes),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	return nil
+}
+
+func cloneOAuthClient(client database.OauthClient) database.OauthClient {
+	client.RedirectUris = slices.Clone(client.RedirectUris)
+	client.Scopes = slices.Clone(client.Scopes)
+	return client
+}
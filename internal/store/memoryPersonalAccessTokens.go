@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreatePersonalAccessToken(ctx context.Context, arg database.CreatePersonalAccessTokenParams) (database.PersonalAccessToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.personalAccessTokens {
+		if token.TokenHash == arg.TokenHash {
+			return database.PersonalAccessToken{}, uniqueViolation("personal_access_tokens", "personal_access_tokens_token_hash_key")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return database.PersonalAccessToken{}, foreignKeyViolation("personal_access_tokens", "personal_access_tokens_user_id_fkey")
+	}
+	token := database.PersonalAccessToken{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scopes:    slices.Clone(arg.Scopes),
+		CreatedAt: m.now(),
+		ExpiresAt: nullTimestamp(arg.ExpiresAt),
+	}
+	m.personalAccessTokens = append(m.personalAccessTokens, token)
+	token.Scopes = slices.Clone(token.Scopes)
+	return token, nil
+}
+
+func (m *Memory) GetPersonalAccessToken(ctx context.Context, tokenHash string) (database.PersonalAccessToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, token := range m.personalAccessTokens {
+		if token.TokenHash == tokenHash {
+			token.Scopes = slices.Clone(token.Scopes)
+			return token, nil
+		}
+	}
+	return database.PersonalAccessToken{}, sql.ErrNoRows
+}
+
+func (m *Memory) ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]database.PersonalAccessToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tokens []database.PersonalAccessToken
+	for _, token := range m.personalAccessTokens {
+		if token.UserID == userID && !token.RevokedAt.Valid {
+			token.Scopes = slices.Clone(token.Scopes)
+			tokens = append(tokens, token)
+		}
+	}
+	slices.SortStableFunc(tokens, func(a, b database.PersonalAccessToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (m *Memory) RevokeAllPersonalAccessTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.personalAccessTokens {
+		token := &m.personalAccessTokens[i]
+		if token.UserID == userID && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokePersonalAccessToken(ctx context.Context, arg database.RevokePersonalAccessTokenParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.personalAccessTokens {
+		token := &m.personalAccessTokens[i]
+		if token.ID == arg.ID && token.UserID == arg.UserID && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullTime{Time: m.now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *Memory) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.personalAccessTokens {
+		if m.personalAccessTokens[i].ID == id {
+			m.personalAccessTokens[i].LastUsedAt = sql.NullTime{Time: m.now(), Valid: true}
+		}
+	}
+	return nil
+}
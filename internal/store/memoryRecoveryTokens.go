@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreateRecoveryToken(ctx context.Context, arg database.CreateRecoveryTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.recoveryTokens {
+		if token.TokenHash == arg.TokenHash {
+			return uniqueViolation("recovery_tokens", "recovery_tokens_token_hash_key")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("recovery_tokens", "recovery_tokens_user_id_fkey")
+	}
+	m.recoveryTokens = append(m.recoveryTokens, database.RecoveryToken{
+		ID:        uuid.New(),
+		TokenHash: arg.TokenHash,
+		UserID:    arg.UserID,
+		CreatedAt: m.now(),
+		ExpiresAt: timestamp(arg.ExpiresAt),
+	})
+	return nil
+}
+
+func (m *Memory) GetRecoveryToken(ctx context.Context, tokenHash string) (database.RecoveryToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, token := range m.recoveryTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return database.RecoveryToken{}, sql.ErrNoRows
+}
+
+func (m *Memory) InvalidateRecoveryTokens(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.recoveryTokens {
+		token := &m.recoveryTokens[i]
+		if token.UserID == userID && !token.UsedAt.Valid {
+			token.UsedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) UseRecoveryToken(ctx context.Context, id uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.recoveryTokens {
+		token := &m.recoveryTokens[i]
+		if token.ID == id && !token.UsedAt.Valid && token.ExpiresAt.After(now) {
+			token.UsedAt = sql.NullTime{Time: now, Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.refreshTokens {
+		if token.TokenHash == arg.TokenHash {
+			return uniqueViolation("refresh_tokens", "refresh_tokens_token_hash_key")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("refresh_tokens", "refresh_tokens_user_id_fkey")
+	}
+	if arg.ClientID.Valid && m.oauthClient(arg.ClientID.UUID) == nil {
+		return foreignKeyViolation("refresh_tokens", "refresh_tokens_client_id_fkey")
+	}
+	now := m.now()
+	m.refreshTokens = append(m.refreshTokens, database.RefreshToken{
+		UserID:    arg.UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: timestamp(arg.ExpiresAt),
+		FamilyID:  arg.FamilyID,
+		ID:        uuid.New(),
+		TokenHash: arg.TokenHash,
+		UserAgent: arg.UserAgent,
+		IpAddress: arg.IpAddress,
+		ClientID:  arg.ClientID,
+		Scopes:    slices.Clone(arg.Scopes),
+	})
+	return nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, token := range m.refreshTokens {
+		if token.TokenHash == tokenHash {
+			token.Scopes = slices.Clone(token.Scopes)
+			return token, nil
+		}
+	}
+	return database.RefreshToken{}, sql.ErrNoRows
+}
+
+// one row per login session (refresh token family) that still has a usable token,
+// most recently used first; tokens held by OAuth clients are not sessions
+func (m *Memory) ListSessions(ctx context.Context, userID uuid.UUID) ([]database.ListSessionsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	var families []uuid.UUID
+	sessions := map[uuid.UUID]*database.ListSessionsRow{}
+	latest := map[uuid.UUID]database.RefreshToken{}
+	active := map[uuid.UUID]bool{}
+	for _, token := range m.refreshTokens {
+		if token.UserID != userID || token.ClientID.Valid {
+			continue
+		}
+		lastUsedAt := token.CreatedAt
+		if token.LastUsedAt.Valid {
+			lastUsedAt = token.LastUsedAt.Time
+		}
+		session, ok := sessions[token.FamilyID]
+		if !ok {
+			families = append(families, token.FamilyID)
+			session = &database.ListSessionsRow{
+				FamilyID:   token.FamilyID,
+				CreatedAt:  token.CreatedAt,
+				LastUsedAt: lastUsedAt,
+				ExpiresAt:  token.ExpiresAt,
+			}
+			sessions[token.FamilyID] = session
+			latest[token.FamilyID] = token
+		}
+		if token.CreatedAt.Before(session.CreatedAt) {
+			session.CreatedAt = token.CreatedAt
+		}
+		if lastUsedAt.After(session.LastUsedAt) {
+			session.LastUsedAt = lastUsedAt
+		}
+		if token.ExpiresAt.After(session.ExpiresAt) {
+			session.ExpiresAt = token.ExpiresAt
+		}
+		if token.CreatedAt.After(latest[token.FamilyID].CreatedAt) {
+			latest[token.FamilyID] = token
+		}
+		if !token.RevokedAt.Valid && token.ExpiresAt.After(now) {
+			active[token.FamilyID] = true
+		}
+	}
+	var rows []database.ListSessionsRow
+	for _, familyID := range families {
+		if !active[familyID] {
+			continue
+		}
+		session := *sessions[familyID]
+		session.UserAgent = latest[familyID].UserAgent
+		session.IpAddress = latest[familyID].IpAddress
+		rows = append(rows, session)
+	}
+	slices.SortStableFunc(rows, func(a, b database.ListSessionsRow) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return rows, nil
+}
+
+func (m *Memory) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	m.revokeRefreshTokens(func(token database.RefreshToken) bool {
+		return token.UserID == userID && !token.RevokedAt.Valid
+	})
+	return nil
+}
+
+func (m *Memory) RevokeClientRefreshTokens(ctx context.Context, arg database.RevokeClientRefreshTokensParams) error {
+	m.revokeRefreshTokens(func(token database.RefreshToken) bool {
+		// NULL never equals NULL, so an invalid ClientID matches nothing
+		return token.UserID == arg.UserID && arg.ClientID.Valid && token.ClientID == arg.ClientID && !token.RevokedAt.Valid
+	})
+	return nil
+}
+
+func (m *Memory) RevokeOtherSessions(ctx context.Context, arg database.RevokeOtherSessionsParams) error {
+	m.revokeRefreshTokens(func(token database.RefreshToken) bool {
+		return token.UserID == arg.UserID && token.FamilyID != arg.FamilyID && !token.RevokedAt.Valid
+	})
+	return nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, id uuid.UUID) error {
+	m.revokeRefreshTokens(func(token database.RefreshToken) bool { return token.ID == id })
+	return nil
+}
+
+func (m *Memory) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.refreshTokens {
+		token := &m.refreshTokens[i]
+		if token.FamilyID == familyID {
+			if !token.RevokedAt.Valid {
+				token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			}
+			token.UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, arg database.RevokeSessionParams) (int64, error) {
+	return m.revokeRefreshTokens(func(token database.RefreshToken) bool {
+		return token.FamilyID == arg.FamilyID && token.UserID == arg.UserID && !token.RevokedAt.Valid
+	}), nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, id uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.refreshTokens {
+		token := &m.refreshTokens[i]
+		if token.ID == id && !token.RevokedAt.Valid {
+			token.RotatedAt = sql.NullTime{Time: now, Valid: true}
+			token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			token.LastUsedAt = sql.NullTime{Time: now, Valid: true}
+			token.UpdatedAt = now
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// mark the matching refresh tokens revoked now, returning how many there were
+func (m *Memory) revokeRefreshTokens(match func(database.RefreshToken) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var revoked int64
+	for i := range m.refreshTokens {
+		token := &m.refreshTokens[i]
+		if match(*token) {
+			token.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			token.UpdatedAt = now
+			revoked++
+		}
+	}
+	return revoked
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CancelSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscription(userID)
+	if subscription == nil || (subscription.Status != "active" && subscription.Status != "past_due") {
+		return 0, nil
+	}
+	now := m.now()
+	subscription.CancelAtPeriodEnd = true
+	if !subscription.CanceledAt.Valid {
+		subscription.CanceledAt = sql.NullTime{Time: now, Valid: true}
+	}
+	subscription.UpdatedAt = now
+	return 1, nil
+}
+
+// expire lapsed subscriptions and take Chirpy Red away from their users, returning those users
+func (m *Memory) ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var userIDs []uuid.UUID
+	for i := range m.subscriptions {
+		subscription := &m.subscriptions[i]
+		if (subscription.Status != "active" && subscription.Status != "past_due") || subscription.CurrentPeriodEnd.After(now) {
+			continue
+		}
+		subscription.Status = "expired"
+		subscription.UpdatedAt = now
+		if user := m.user(subscription.UserID); user != nil {
+			user.IsChirpyRed = false
+			user.UpdatedAt = now
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (m *Memory) GetSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscription := m.subscription(userID)
+	if subscription == nil {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	return *subscription, nil
+}
+
+func (m *Memory) MarkSubscriptionPastDue(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscription(userID)
+	if subscription == nil || subscription.Status != "active" {
+		return 0, nil
+	}
+	subscription.Status = "past_due"
+	subscription.UpdatedAt = m.now()
+	return 1, nil
+}
+
+func (m *Memory) RefundSubscription(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscription(userID)
+	if subscription == nil {
+		return 0, nil
+	}
+	now := m.now()
+	subscription.Status = "refunded"
+	if subscription.CurrentPeriodEnd.After(now) {
+		subscription.CurrentPeriodEnd = now
+	}
+	if !subscription.CanceledAt.Valid {
+		subscription.CanceledAt = sql.NullTime{Time: now, Valid: true}
+	}
+	subscription.UpdatedAt = now
+	return 1, nil
+}
+
+// start or renew a user's subscription, clearing any pending cancellation
+func (m *Memory) StartSubscription(ctx context.Context, arg database.StartSubscriptionParams) (database.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(arg.UserID) == nil {
+		return database.Subscription{}, foreignKeyViolation("subscriptions", "subscriptions_user_id_fkey")
+	}
+	now := m.now()
+	if subscription := m.subscription(arg.UserID); subscription != nil {
+		subscription.Plan = arg.Plan
+		subscription.Status = "active"
+		subscription.CurrentPeriodStart = timestamp(arg.CurrentPeriodStart)
+		subscription.CurrentPeriodEnd = timestamp(arg.CurrentPeriodEnd)
+		subscription.CancelAtPeriodEnd = false
+		subscription.CanceledAt = sql.NullTime{}
+		subscription.UpdatedAt = now
+		return *subscription, nil
+	}
+	subscription := database.Subscription{
+		ID:                 uuid.New(),
+		UserID:             arg.UserID,
+		Plan:               arg.Plan,
+		Status:             "active",
+		CurrentPeriodStart: timestamp(arg.CurrentPeriodStart),
+		CurrentPeriodEnd:   timestamp(arg.CurrentPeriodEnd),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	m.subscriptions = append(m.subscriptions, subscription)
+	return subscription, nil
+}
+
+func (m *Memory) subscription(userID uuid.UUID) *database.Subscription {
+	for i := range m.subscriptions {
+		if m.subscriptions[i].UserID == userID {
+			return &m.subscriptions[i]
+		}
+	}
+	return nil
+}
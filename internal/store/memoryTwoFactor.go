@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+func (m *Memory) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.recoveryCodes {
+		if code.UserID == arg.UserID && code.CodeHash == arg.CodeHash {
+			return uniqueViolation("two_factor_recovery_codes", "two_factor_recovery_codes_user_id_code_hash_key")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("two_factor_recovery_codes", "two_factor_recovery_codes_user_id_fkey")
+	}
+	m.recoveryCodes = append(m.recoveryCodes, database.TwoFactorRecoveryCode{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		CodeHash:  arg.CodeHash,
+		CreatedAt: m.now(),
+	})
+	return nil
+}
+
+func (m *Memory) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(code database.TwoFactorRecoveryCode) bool {
+		return code.UserID == userID
+	})
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.recoveryCodes {
+		code := &m.recoveryCodes[i]
+		if code.UserID == arg.UserID && code.CodeHash == arg.CodeHash && !code.UsedAt.Valid {
+			code.UsedAt = sql.NullTime{Time: m.now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Lokee86/serverProject/internal/database"
+)
+
+func (m *Memory) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, identity := range m.userIdentities {
+		if identity.Issuer == arg.Issuer && identity.Subject == arg.Subject {
+			return uniqueViolation("user_identities", "user_identities_pkey")
+		}
+	}
+	if m.user(arg.UserID) == nil {
+		return foreignKeyViolation("user_identities", "user_identities_user_id_fkey")
+	}
+	now := m.now()
+	m.userIdentities = append(m.userIdentities, database.UserIdentity{
+		Issuer:      arg.Issuer,
+		Subject:     arg.Subject,
+		UserID:      arg.UserID,
+		Email:       arg.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	return nil
+}
+
+func (m *Memory) GetUserByIdentity(ctx context.Context, arg database.GetUserByIdentityParams) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, identity := range m.userIdentities {
+		if identity.Issuer == arg.Issuer && identity.Subject == arg.Subject {
+			if user := m.user(identity.UserID); user != nil {
+				return *user, nil
+			}
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) TouchUserIdentity(ctx context.Context, arg database.TouchUserIdentityParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.userIdentities {
+		identity := &m.userIdentities[i]
+		if identity.Issuer == arg.Issuer && identity.Subject == arg.Subject {
+			identity.Email = arg.Email
+			identity.LastLoginAt = m.now()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+var userRoles = map[string]bool{"user": true, "moderator": true, "admin": true}
+
+func (m *Memory) ActivateChirpyRed(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(id); user != nil {
+		user.IsChirpyRed = true
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) CancelAccountDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user(id)
+	if user == nil || !user.DeletionScheduledAt.Valid {
+		return 0, nil
+	}
+	user.DeletionScheduledAt = sql.NullTime{}
+	user.UpdatedAt = m.now()
+	return 1, nil
+}
+
+func (m *Memory) ConfirmPendingEmail(ctx context.Context, arg database.ConfirmPendingEmailParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user(arg.ID)
+	if user == nil || !user.PendingEmail.Valid || !arg.PendingEmail.Valid || user.PendingEmail.String != arg.PendingEmail.String {
+		return 0, nil
+	}
+	if m.emailTaken(user.PendingEmail.String, user.ID) {
+		return 0, uniqueViolation("users", "users_email_key")
+	}
+	now := m.now()
+	user.Email = user.PendingEmail.String
+	user.PendingEmail = sql.NullString{}
+	user.EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
+	user.UpdatedAt = now
+	return 1, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, uniqueViolation("users", "users_email_key")
+	}
+	now := m.now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+	}
+	m.users = append(m.users, user)
+	return user, nil
+}
+
+func (m *Memory) DeactivateChirpyRed(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(id); user != nil {
+		user.IsChirpyRed = false
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) DeleteScheduledAccounts(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	return m.deleteUsers(func(user database.User) bool {
+		return user.DeletionScheduledAt.Valid && !user.DeletionScheduledAt.Time.After(now)
+	}), nil
+}
+
+func (m *Memory) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(id); user != nil {
+		user.TotpSecret = sql.NullString{}
+		user.TotpEnabledAt = sql.NullTime{}
+		user.TotpLastStep = 0
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) EnableTOTP(ctx context.Context, arg database.EnableTOTPParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		now := m.now()
+		user.TotpEnabledAt = sql.NullTime{Time: now, Valid: true}
+		user.TotpLastStep = arg.TotpLastStep
+		user.UpdatedAt = now
+	}
+	return nil
+}
+
+func (m *Memory) GetTokensValidAfter(ctx context.Context, id uuid.UUID) (sql.NullTime, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.user(id)
+	if user == nil {
+		return sql.NullTime{}, sql.ErrNoRows
+	}
+	return user.TokensValidAfter, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.user(id)
+	if user == nil {
+		return database.User{}, sql.ErrNoRows
+	}
+	return *user, nil
+}
+
+func (m *Memory) MarkEmailVerified(ctx context.Context, arg database.MarkEmailVerifiedParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user(arg.ID)
+	if user == nil || user.Email != arg.Email {
+		return 0, nil
+	}
+	now := m.now()
+	user.EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
+	user.UpdatedAt = now
+	return 1, nil
+}
+
+func (m *Memory) RecordTOTPStep(ctx context.Context, arg database.RecordTOTPStepParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user(arg.ID)
+	if user == nil || user.TotpLastStep >= arg.TotpLastStep {
+		return 0, nil
+	}
+	user.TotpLastStep = arg.TotpLastStep
+	return 1, nil
+}
+
+func (m *Memory) ResetUsers(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteUsers(func(database.User) bool { return true })
+	return nil
+}
+
+func (m *Memory) RevokeTokensIssuedBefore(ctx context.Context, arg database.RevokeTokensIssuedBeforeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		user.TokensValidAfter = nullTimestamp(arg.TokensValidAfter)
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) ScheduleAccountDeletion(ctx context.Context, arg database.ScheduleAccountDeletionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		user.DeletionScheduledAt = nullTimestamp(arg.DeletionScheduledAt)
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) SetPendingEmail(ctx context.Context, arg database.SetPendingEmailParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		user.PendingEmail = arg.PendingEmail
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) SetTOTPSecret(ctx context.Context, arg database.SetTOTPSecretParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		user.TotpSecret = arg.TotpSecret
+		user.TotpEnabledAt = sql.NullTime{}
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.users {
+		if m.users[i].Email != arg.Email {
+			continue
+		}
+		if !userRoles[arg.Role] {
+			return 0, checkViolation("users", "users_role_check")
+		}
+		m.users[i].Role = arg.Role
+		m.users[i].UpdatedAt = m.now()
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *Memory) UpdateAccount(ctx context.Context, arg database.UpdateAccountParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user(arg.ID)
+	if user == nil {
+		return database.User{}, sql.ErrNoRows
+	}
+	if arg.HashedPassword.Valid {
+		user.HashedPassword = arg.HashedPassword.String
+	}
+	if arg.PendingEmail.Valid {
+		user.PendingEmail = arg.PendingEmail
+	}
+	user.UpdatedAt = m.now()
+	return *user, nil
+}
+
+func (m *Memory) UpdatePassword(ctx context.Context, arg database.UpdatePasswordParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(arg.ID); user != nil {
+		user.HashedPassword = arg.HashedPassword
+		user.UpdatedAt = m.now()
+	}
+	return nil
+}
+
+// whether a user other than except already has email
+func (m *Memory) emailTaken(email string, except uuid.UUID) bool {
+	for _, user := range m.users {
+		if user.Email == email && user.ID != except {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+var webhookDeliveryStatuses = map[string]bool{"pending": true, "delivered": true, "dead": true}
+
+// how long a claimed delivery is held before another sender may pick it up
+const webhookDeliveryLease = 5 * time.Minute
+
+// due deliveries are leased for a few minutes so a crashed sender's work is picked up again
+func (m *Memory) ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]database.ClaimWebhookDeliveriesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var due []*database.WebhookDelivery
+	for i := range m.webhookDeliveries {
+		delivery := &m.webhookDeliveries[i]
+		if delivery.Status == "pending" && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *database.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	if len(due) > int(limit) {
+		due = due[:limit]
+	}
+	var rows []database.ClaimWebhookDeliveriesRow
+	for _, delivery := range due {
+		endpoint := m.webhookEndpoint(delivery.EndpointID)
+		if endpoint == nil {
+			continue
+		}
+		delivery.NextAttemptAt = now.Add(webhookDeliveryLease)
+		rows = append(rows, database.ClaimWebhookDeliveriesRow{
+			ID:        delivery.ID,
+			EventType: delivery.EventType,
+			Payload:   cloneJSON(delivery.Payload),
+			Attempts:  delivery.Attempts,
+			Url:       endpoint.Url,
+			Secret:    endpoint.Secret,
+		})
+	}
+	return rows, nil
+}
+
+func (m *Memory) CreateWebhookEndpoint(ctx context.Context, arg database.CreateWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.user(arg.UserID) == nil {
+		return database.WebhookEndpoint{}, foreignKeyViolation("webhook_endpoints", "webhook_endpoints_user_id_fkey")
+	}
+	endpoint := database.WebhookEndpoint{
+		ID:        uuid.New(),
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    slices.Clone(arg.Events),
+		CreatedAt: m.now(),
+	}
+	m.webhookEndpoints = append(m.webhookEndpoints, endpoint)
+	endpoint.Events = slices.Clone(endpoint.Events)
+	return endpoint, nil
+}
+
+func (m *Memory) DeleteWebhookEndpoint(ctx context.Context, arg database.DeleteWebhookEndpointParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool {
+		return endpoint.ID == arg.ID && endpoint.UserID == arg.UserID
+	}), nil
+}
+
+// admins' endpoints hear about every user, everyone else's only about themselves
+func (m *Memory) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var queued int64
+	for _, endpoint := range m.webhookEndpoints {
+		if !slices.Contains(endpoint.Events, arg.EventType) {
+			continue
+		}
+		owner := m.user(endpoint.UserID)
+		if owner == nil || (owner.ID != arg.SubjectUserID && owner.Role != "admin") {
+			continue
+		}
+		m.webhookDeliveries = append(m.webhookDeliveries, database.WebhookDelivery{
+			ID:            uuid.New(),
+			EndpointID:    endpoint.ID,
+			EventType:     arg.EventType,
+			Payload:       cloneJSON(arg.Payload),
+			Status:        "pending",
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		queued++
+	}
+	return queued, nil
+}
+
+func (m *Memory) FailWebhookDelivery(ctx context.Context, arg database.FailWebhookDeliveryParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.webhookDelivery(arg.ID)
+	if delivery == nil {
+		return nil
+	}
+	if !webhookDeliveryStatuses[arg.Status] {
+		return checkViolation("webhook_deliveries", "webhook_deliveries_status_check")
+	}
+	delivery.Status = arg.Status
+	delivery.Attempts++
+	delivery.NextAttemptAt = timestamp(arg.NextAttemptAt)
+	delivery.LastStatusCode = arg.LastStatusCode
+	delivery.LastError = arg.LastError
+	return nil
+}
+
+func (m *Memory) GetWebhookEndpoint(ctx context.Context, arg database.GetWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	endpoint := m.webhookEndpoint(arg.ID)
+	if endpoint == nil || endpoint.UserID != arg.UserID {
+		return database.WebhookEndpoint{}, sql.ErrNoRows
+	}
+	found := *endpoint
+	found.Events = slices.Clone(endpoint.Events)
+	return found, nil
+}
+
+func (m *Memory) ListWebhookDeliveries(ctx context.Context, endpointID uuid.UUID) ([]database.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var deliveries []database.WebhookDelivery
+	for _, delivery := range m.webhookDeliveries {
+		if delivery.EndpointID == endpointID {
+			delivery.Payload = cloneJSON(delivery.Payload)
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortStableFunc(deliveries, func(a, b database.WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(deliveries) > 100 {
+		deliveries = deliveries[:100]
+	}
+	return deliveries, nil
+}
+
+func (m *Memory) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var endpoints []database.WebhookEndpoint
+	for _, endpoint := range m.webhookEndpoints {
+		if endpoint.UserID == userID {
+			endpoint.Events = slices.Clone(endpoint.Events)
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	slices.SortStableFunc(endpoints, func(a, b database.WebhookEndpoint) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return endpoints, nil
+}
+
+func (m *Memory) MarkWebhookDelivered(ctx context.Context, arg database.MarkWebhookDeliveredParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if delivery := m.webhookDelivery(arg.ID); delivery != nil {
+		delivery.Status = "delivered"
+		delivery.Attempts++
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = sql.NullString{}
+		delivery.DeliveredAt = sql.NullTime{Time: m.now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *Memory) webhookDelivery(id uuid.UUID) *database.WebhookDelivery {
+	for i := range m.webhookDeliveries {
+		if m.webhookDeliveries[i].ID == id {
+			return &m.webhookDeliveries[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+)
+
+var webhookEventStatuses = map[string]bool{"processing": true, "processed": true, "ignored": true, "failed": true}
+
+// how long an event may sit in processing before it is taken to have been abandoned
+const webhookEventStallTimeout = 5 * time.Minute
+
+func (m *Memory) FinishWebhookEvent(ctx context.Context, arg database.FinishWebhookEventParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event := m.webhookEvent(arg.ID)
+	if event == nil {
+		return nil
+	}
+	if !webhookEventStatuses[arg.Status] {
+		return checkViolation("webhook_events", "webhook_events_status_check")
+	}
+	event.Status = arg.Status
+	event.LastError = arg.LastError
+	event.ProcessedAt = sql.NullTime{Time: m.now(), Valid: true}
+	return nil
+}
+
+func (m *Memory) GetWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	event := m.webhookEvent(id)
+	if event == nil {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	return cloneWebhookEvent(*event), nil
+}
+
+func (m *Memory) ListWebhookEvents(ctx context.Context, status sql.NullString) ([]database.WebhookEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []database.WebhookEvent
+	for _, event := range m.webhookEvents {
+		if !status.Valid || event.Status == status.String {
+			events = append(events, cloneWebhookEvent(event))
+		}
+	}
+	slices.SortStableFunc(events, func(a, b database.WebhookEvent) int {
+		return b.ReceivedAt.Compare(a.ReceivedAt)
+	})
+	if len(events) > 100 {
+		events = events[:100]
+	}
+	return events, nil
+}
+
+// a redelivered event is only handed back for processing when its earlier attempt failed,
+// or was abandoned in processing for longer than any attempt takes
+func (m *Memory) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (database.WebhookEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.webhookEvents {
+		event := &m.webhookEvents[i]
+		if event.Provider != arg.Provider || event.EventID != arg.EventID {
+			continue
+		}
+		if !m.webhookEventRetryable(event) {
+			return database.WebhookEvent{}, sql.ErrNoRows
+		}
+		m.restartWebhookEvent(event)
+		return cloneWebhookEvent(*event), nil
+	}
+	now := m.now()
+	event := database.WebhookEvent{
+		ID:                  uuid.New(),
+		Provider:            arg.Provider,
+		EventID:             arg.EventID,
+		EventType:           arg.EventType,
+		Payload:             cloneJSON(arg.Payload),
+		Status:              "processing",
+		Attempts:            1,
+		ReceivedAt:          now,
+		ProcessingStartedAt: now,
+	}
+	m.webhookEvents = append(m.webhookEvents, event)
+	return cloneWebhookEvent(event), nil
+}
+
+func (m *Memory) RetryWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event := m.webhookEvent(id)
+	if event == nil || !m.webhookEventRetryable(event) {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	m.restartWebhookEvent(event)
+	return cloneWebhookEvent(*event), nil
+}
+
+func (m *Memory) webhookEvent(id uuid.UUID) *database.WebhookEvent {
+	for i := range m.webhookEvents {
+		if m.webhookEvents[i].ID == id {
+			return &m.webhookEvents[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) webhookEventRetryable(event *database.WebhookEvent) bool {
+	switch event.Status {
+	case "failed":
+		return true
+	case "processing":
+		return event.ProcessingStartedAt.Before(m.now().Add(-webhookEventStallTimeout))
+	}
+	return false
+}
+
+func (m *Memory) restartWebhookEvent(event *database.WebhookEvent) {
+	event.Status = "processing"
+	event.Attempts++
+	event.ProcessingStartedAt = m.now()
+}
+
+func cloneWebhookEvent(event database.WebhookEvent) database.WebhookEvent {
+	event.Payload = cloneJSON(event.Payload)
+	return event
+}
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// a Memory whose clock only moves when advance is called
+func newTestMemory() (*Memory, func(time.Duration)) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	memory := NewMemory()
+	memory.Now = func() time.Time { return now }
+	return memory, func(d time.Duration) { now = now.Add(d) }
+}
+
+func pqCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+func mustCreateUser(t *testing.T, memory *Memory, email string) database.User {
+	t.Helper()
+	user, err := memory.CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser(%v) failed: %v", email, err)
+	}
+	return user
+}
+
+func TestMemoryUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := newTestMemory()
+	mustCreateUser(t, memory, "taken@example.com")
+	user := mustCreateUser(t, memory, "other@example.com")
+
+	_, err := memory.CreateUser(ctx, database.CreateUserParams{Email: "taken@example.com", HashedPassword: "hash"})
+	if pqCode(err) != "23505" {
+		t.Errorf("expected a unique violation for a duplicate email, got %v", err)
+	}
+
+	pending := sql.NullString{String: "taken@example.com", Valid: true}
+	err = memory.SetPendingEmail(ctx, database.SetPendingEmailParams{PendingEmail: pending, ID: user.ID})
+	if err != nil {
+		t.Fatalf("SetPendingEmail failed: %v", err)
+	}
+	_, err = memory.ConfirmPendingEmail(ctx, database.ConfirmPendingEmailParams{ID: user.ID, PendingEmail: pending})
+	if pqCode(err) != "23505" {
+		t.Errorf("expected confirming a taken email to be a unique violation, got %v", err)
+	}
+}
+
+func TestMemoryConcurrentCreateUser(t *testing.T) {
+	memory, _ := newTestMemory()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := memory.CreateUser(context.Background(), database.CreateUserParams{Email: "race@example.com"})
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			} else if pqCode(err) != "23505" {
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("expected exactly one user to be created, got %v", created)
+	}
+}
+
+func TestMemoryForeignKeys(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := newTestMemory()
+	missing := uuid.New()
+
+	_, err := memory.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: missing})
+	if pqCode(err) != "23503" {
+		t.Errorf("expected a chirp by a missing user to violate its foreign key, got %v", err)
+	}
+	_, err = memory.StartSubscription(ctx, database.StartSubscriptionParams{UserID: missing, Plan: "chirpy_red"})
+	if pqCode(err) != "23503" {
+		t.Errorf("expected a subscription for a missing user to violate its foreign key, got %v", err)
+	}
+	if _, err = memory.GetUserByID(ctx, missing); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing user, got %v", err)
+	}
+}
+
+func TestMemoryChirpOrdering(t *testing.T) {
+	ctx := context.Background()
+	memory, advance := newTestMemory()
+	alice := mustCreateUser(t, memory, "alice@example.com")
+	bob := mustCreateUser(t, memory, "bob@example.com")
+	for _, chirp := range []database.CreateChirpParams{
+		{Body: "first", UserID: alice.ID},
+		{Body: "second", UserID: bob.ID},
+		{Body: "third", UserID: alice.ID},
+	} {
+		if _, err := memory.CreateChirp(ctx, chirp); err != nil {
+			t.Fatalf("CreateChirp failed: %v", err)
+		}
+		advance(time.Second)
+	}
+
+	bodies := func(chirps []database.Chirp) []string {
+		result := []string{}
+		for _, chirp := range chirps {
+			result = append(result, chirp.Body)
+		}
+		return result
+	}
+	all, _ := memory.GetAllChirps(ctx)
+	allDesc, _ := memory.GetAllChirpsDesc(ctx)
+	alices, _ := memory.GetChirpsByID(ctx, alice.ID)
+	alicesDesc, _ := memory.GetChirpsByIDDesc(ctx, alice.ID)
+	tests := map[string]struct {
+		got  []string
+		want []string
+	}{
+		"all ascending":   {bodies(all), []string{"first", "second", "third"}},
+		"all descending":  {bodies(allDesc), []string{"third", "second", "first"}},
+		"by author":       {bodies(alices), []string{"first", "third"}},
+		"by author, desc": {bodies(alicesDesc), []string{"third", "first"}},
+	}
+	for name, test := range tests {
+		if len(test.got) != len(test.want) {
+			t.Errorf("%v: expected %v, got %v", name, test.want, test.got)
+			continue
+		}
+		for i := range test.want {
+			if test.got[i] != test.want[i] {
+				t.Errorf("%v: expected %v, got %v", name, test.want, test.got)
+				break
+			}
+		}
+	}
+
+	since, _ := memory.CountChirpsSince(ctx, database.CountChirpsSinceParams{UserID: alice.ID, CreatedAt: all[0].CreatedAt})
+	if since != 1 {
+		t.Errorf("expected one of alice's chirps after her first, got %v", since)
+	}
+}
+
+func TestMemoryCascadeDelete(t *testing.T) {
+	ctx := context.Background()
+	memory, advance := newTestMemory()
+	leaving := mustCreateUser(t, memory, "leaving@example.com")
+	staying := mustCreateUser(t, memory, "staying@example.com")
+
+	for _, user := range []database.User{leaving, staying} {
+		if _, err := memory.CreateChirp(ctx, database.CreateChirpParams{Body: "chirp", UserID: user.ID}); err != nil {
+			t.Fatalf("CreateChirp failed: %v", err)
+		}
+		err := memory.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			TokenHash: user.Email,
+			UserID:    user.ID,
+			ExpiresAt: memory.Now().Add(time.Hour),
+			FamilyID:  uuid.New(),
+		})
+		if err != nil {
+			t.Fatalf("CreateRefreshToken failed: %v", err)
+		}
+	}
+	// a client owned by the leaving user takes the tokens issued to it along too
+	client, err := memory.CreateOAuthClient(ctx, database.CreateOAuthClientParams{UserID: leaving.ID, Name: "app"})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient failed: %v", err)
+	}
+	err = memory.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: "client-token",
+		UserID:    staying.ID,
+		ExpiresAt: memory.Now().Add(time.Hour),
+		FamilyID:  uuid.New(),
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken failed: %v", err)
+	}
+
+	err = memory.ScheduleAccountDeletion(ctx, database.ScheduleAccountDeletionParams{
+		DeletionScheduledAt: sql.NullTime{Time: memory.Now().Add(time.Hour), Valid: true},
+		ID:                  leaving.ID,
+	})
+	if err != nil {
+		t.Fatalf("ScheduleAccountDeletion failed: %v", err)
+	}
+	if deleted, _ := memory.DeleteScheduledAccounts(ctx); deleted != 0 {
+		t.Fatalf("expected nothing deleted before the grace period, got %v", deleted)
+	}
+	advance(time.Hour)
+	if deleted, _ := memory.DeleteScheduledAccounts(ctx); deleted != 1 {
+		t.Fatalf("expected one account deleted, got %v", deleted)
+	}
+
+	if chirps, _ := memory.GetChirpsByID(ctx, leaving.ID); len(chirps) != 0 {
+		t.Errorf("expected the deleted user's chirps to go with them, got %v", chirps)
+	}
+	for _, hash := range []string{leaving.Email, "client-token"} {
+		if _, err := memory.GetRefreshToken(ctx, hash); err != sql.ErrNoRows {
+			t.Errorf("expected refresh token %v to be deleted, got %v", hash, err)
+		}
+	}
+	if _, err := memory.GetOAuthClient(ctx, client.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the deleted user's OAuth client to be deleted, got %v", err)
+	}
+	if chirps, _ := memory.GetChirpsByID(ctx, staying.ID); len(chirps) != 1 {
+		t.Errorf("expected the other user's chirp to remain, got %v", chirps)
+	}
+	if _, err := memory.GetRefreshToken(ctx, staying.Email); err != nil {
+		t.Errorf("expected the other user's refresh token to remain: %v", err)
+	}
+}
+
+func TestMemoryRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	memory, advance := newTestMemory()
+	user := mustCreateUser(t, memory, "user@example.com")
+	familyID := uuid.New()
+	err := memory.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: "first",
+		UserID:    user.ID,
+		ExpiresAt: memory.Now().Add(time.Hour),
+		FamilyID:  familyID,
+		UserAgent: "old browser",
+		Scopes:    []string{"chirps:read"},
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken failed: %v", err)
+	}
+	err = memory.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{TokenHash: "first", UserID: user.ID, FamilyID: familyID})
+	if pqCode(err) != "23505" {
+		t.Errorf("expected a reused token hash to be a unique violation, got %v", err)
+	}
+
+	first, err := memory.GetRefreshToken(ctx, "first")
+	if err != nil {
+		t.Fatalf("GetRefreshToken failed: %v", err)
+	}
+	first.Scopes[0] = "changed"
+	if stored, _ := memory.GetRefreshToken(ctx, "first"); stored.Scopes[0] != "chirps:read" {
+		t.Error("expected returned rows not to share memory with the store")
+	}
+
+	advance(time.Minute)
+	if rotated, _ := memory.RotateRefreshToken(ctx, first.ID); rotated != 1 {
+		t.Fatalf("expected the token to rotate once, got %v", rotated)
+	}
+	if rotated, _ := memory.RotateRefreshToken(ctx, first.ID); rotated != 0 {
+		t.Errorf("expected a rotated token not to rotate again, got %v", rotated)
+	}
+	err = memory.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: "second",
+		UserID:    user.ID,
+		ExpiresAt: memory.Now().Add(time.Hour),
+		FamilyID:  familyID,
+		UserAgent: "new browser",
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken failed: %v", err)
+	}
+
+	sessions, _ := memory.ListSessions(ctx, user.ID)
+	if len(sessions) != 1 {
+		t.Fatalf("expected one session for the token family, got %v", sessions)
+	}
+	if sessions[0].UserAgent != "new browser" || !sessions[0].CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("expected the session to start with the first token and show the latest device, got %+v", sessions[0])
+	}
+
+	if revoked, _ := memory.RevokeSession(ctx, database.RevokeSessionParams{FamilyID: familyID, UserID: user.ID}); revoked != 1 {
+		t.Errorf("expected the live token in the session to be revoked, got %v", revoked)
+	}
+	if sessions, _ := memory.ListSessions(ctx, user.ID); len(sessions) != 0 {
+		t.Errorf("expected no sessions after revoking, got %v", sessions)
+	}
+}
+
+func TestMemoryWebhookEventRedelivery(t *testing.T) {
+	ctx := context.Background()
+	memory, advance := newTestMemory()
+	params := database.RecordWebhookEventParams{Provider: "polka", EventID: "evt_1", EventType: "user.upgraded", Payload: []byte(`{}`)}
+	event, err := memory.RecordWebhookEvent(ctx, params)
+	if err != nil {
+		t.Fatalf("RecordWebhookEvent failed: %v", err)
+	}
+	if _, err := memory.RecordWebhookEvent(ctx, params); err != sql.ErrNoRows {
+		t.Errorf("expected a redelivery during processing to be a duplicate, got %v", err)
+	}
+	if _, err := memory.RetryWebhookEvent(ctx, event.ID); err != sql.ErrNoRows {
+		t.Errorf("expected an event still in processing not to be retried, got %v", err)
+	}
+
+	advance(webhookEventStallTimeout + time.Second)
+	retried, err := memory.RecordWebhookEvent(ctx, params)
+	if err != nil {
+		t.Fatalf("expected a redelivery of an abandoned event to be processed again, got %v", err)
+	}
+	if retried.ID != event.ID || retried.Attempts != 2 || !retried.ProcessingStartedAt.Equal(memory.Now()) {
+		t.Errorf("expected the abandoned event to be restarted, got %+v", retried)
+	}
+	if _, err := memory.RetryWebhookEvent(ctx, event.ID); err != sql.ErrNoRows {
+		t.Errorf("expected a restarted event not to be retried again at once, got %v", err)
+	}
+
+	advance(webhookEventStallTimeout + time.Second)
+	if retried, err = memory.RetryWebhookEvent(ctx, event.ID); err != nil || retried.Attempts != 3 {
+		t.Errorf("expected an abandoned event to be replayable, got %+v, %v", retried, err)
+	}
+	err = memory.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{ID: event.ID, Status: "processed"})
+	if err != nil {
+		t.Fatalf("FinishWebhookEvent failed: %v", err)
+	}
+	advance(webhookEventStallTimeout + time.Second)
+	if _, err := memory.RecordWebhookEvent(ctx, params); err != sql.ErrNoRows {
+		t.Errorf("expected a redelivery of a processed event to be a duplicate, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Package store is the boundary between the HTTP handlers and wherever
+// Chirpy's data lives.
+//
+// The sqlc-generated *database.Queries is the Postgres implementation. Memory
+// keeps everything in process with the same semantics, so the server can be
+// exercised without a database.
+package store
+
+import "github.com/Lokee86/serverProject/internal/database"
+
+// Store is every query the server runs: users, chirps and refresh tokens, and
+// the sessions, credentials, subscriptions and webhooks hanging off them.
+type Store interface {
+	database.Querier
+}
+
+var (
+	_ Store = (*database.Queries)(nil)
+	_ Store = (*Memory)(nil)
+)
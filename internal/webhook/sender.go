@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// EventHeader names the event a delivery is for.
+const EventHeader = "Webhook-Event"
+
+// ErrPrivateAddress is returned when a receiver's address is not on the public internet.
+var ErrPrivateAddress = errors.New("webhook receiver address is not public")
+
+// Delivery is one signed POST of an event to a receiver.
+type Delivery struct {
+	// ID stays the same across retries so receivers can drop duplicates
+	ID        string
+	URL       string
+	Secret    string
+	EventType string
+	Payload   []byte
+}
+
+// Sender posts webhook deliveries.
+type Sender struct {
+	Client *http.Client
+	Now    func() time.Time
+}
+
+// NewSender returns a Sender that only connects to public addresses. The check is made
+// on the address actually dialled, so a receiver's name cannot be re-pointed at an
+// internal host after it was registered.
+func NewSender() *Sender {
+	return newSender(publicOnly)
+}
+
+func newSender(control func(network, address string, c syscall.RawConn) error) *Sender {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialled in the receiver's place, escaping the address check
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}).DialContext
+	return &Sender{
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+			// a receiver must answer itself rather than send the event elsewhere
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Now: time.Now,
+	}
+}
+
+// Send posts a delivery and returns the receiver's status code. Anything but
+// a 2xx response is an error; the status code is 0 when none was received.
+func (s *Sender) Send(ctx context.Context, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(IDHeader, delivery.ID)
+	req.Header.Set(EventHeader, delivery.EventType)
+	SetHeaders(req.Header, []string{delivery.Secret}, s.Now(), delivery.Payload)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %v", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// PublicAddress reports whether addr may be sent webhooks: anything loopback, private,
+// link-local, multicast or unspecified is refused.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// refuse to connect anywhere but a public address
+func publicOnly(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !PublicAddress(addrPort.Addr()) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Backoff is how long to wait before retrying a delivery that has failed attempts times,
+// doubling from 30 seconds up to a cap of six hours.
+func Backoff(attempts int) time.Duration {
+	const base, max = 30 * time.Second, 6 * time.Hour
+	if attempts < 1 {
+		return base
+	}
+	if attempts > 20 {
+		return max
+	}
+	delay := base << (attempts - 1)
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestVerifier(now *time.Time, keys ...string) *Verifier {
+	verifier := NewVerifier(keys)
+	verifier.Now = func() time.Time { return *now }
+	return verifier
+}
+
+func signedHeader(keys []string, timestamp time.Time, body []byte) http.Header {
+	header := http.Header{}
+	SetHeaders(header, keys, timestamp, body)
+	return header
+}
+
+func TestVerifySignedPayload(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	verifier := newTestVerifier(&now, "current")
+	body := []byte(`{"event":"user.upgraded"}`)
+
+	if err := verifier.Verify(signedHeader([]string{"current"}, now, body), body); err != nil {
+		t.Fatalf("expected signed payload to verify: %v", err)
+	}
+
+	tests := map[string]struct {
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		"tampered body":   {signedHeader([]string{"current"}, now, body), []byte(`{"event":"user.downgraded"}`), ErrInvalidSignature},
+		"wrong key":       {signedHeader([]string{"guess"}, now, body), body, ErrInvalidSignature},
+		"replayed":        {signedHeader([]string{"current"}, now.Add(-10*time.Minute), body), body, ErrStaleTimestamp},
+		"from the future": {signedHeader([]string{"current"}, now.Add(10*time.Minute), body), body, ErrStaleTimestamp},
+		"unsigned":        {http.Header{}, body, ErrMissingSignature},
+	}
+	for name, test := range tests {
+		if err := verifier.Verify(test.header, test.body); err != test.want {
+			t.Errorf("%v: expected %v, got %v", name, test.want, err)
+		}
+	}
+
+	// moving the timestamp breaks the signature even when it is still fresh
+	moved := signedHeader([]string{"current"}, now.Add(-time.Minute), body)
+	moved.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	if err := verifier.Verify(moved, body); err != ErrInvalidSignature {
+		t.Errorf("expected a moved timestamp to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{}`)
+	rotating := newTestVerifier(&now, "new", "old")
+
+	if err := rotating.Verify(signedHeader([]string{"old"}, now, body), body); err != nil {
+		t.Errorf("expected the previous key to be accepted during rotation: %v", err)
+	}
+	if err := rotating.Verify(signedHeader([]string{"new"}, now, body), body); err != nil {
+		t.Errorf("expected the current key to be accepted: %v", err)
+	}
+
+	rotated := newTestVerifier(&now, "new")
+	if err := rotated.Verify(signedHeader([]string{"old"}, now, body), body); err != ErrInvalidSignature {
+		t.Errorf("expected the retired key to be rejected, got %v", err)
+	}
+	// a sender signing with both keys works on either side of the switch
+	if err := rotated.Verify(signedHeader([]string{"old", "new"}, now, body), body); err != nil {
+		t.Errorf("expected a payload signed with both keys to verify: %v", err)
+	}
+
+	if err := NewVerifier(nil).Verify(signedHeader([]string{""}, now, body), body); err != ErrNoKeys {
+		t.Errorf("expected verification without keys to fail, got %v", err)
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	verifier := NewVerifier([]string{"new", "old"})
+	for _, key := range []string{"new", "old"} {
+		if !verifier.ValidKey(key) {
+			t.Errorf("expected %q to be accepted", key)
+		}
+	}
+	for _, key := range []string{"", "ne", "newer"} {
+		if verifier.ValidKey(key) {
+			t.Errorf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestKeysFromEnv(t *testing.T) {
+	keys := KeysFromEnv(" new, ,old ")
+	if len(keys) != 2 || keys[0] != "new" || keys[1] != "old" {
+		t.Errorf("unexpected keys %q", keys)
+	}
+}
+
+func TestSenderDelivery(t *testing.T) {
+	verifier := NewVerifier([]string{"whsec_test"})
+	received := make(chan http.Header, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifier.Verify(r.Header, body); err != nil || string(body) != `{"event":"chirp.created"}` {
+			t.Errorf("receiver got an unverifiable delivery %q: %v", body, err)
+		}
+		received <- r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	status, err := newSender(nil).Send(context.Background(), Delivery{
+		ID:        "delivery-1",
+		URL:       receiver.URL,
+		Secret:    "whsec_test",
+		EventType: "chirp.created",
+		Payload:   []byte(`{"event":"chirp.created"}`),
+	})
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("expected delivery to succeed, got %v: %v", status, err)
+	}
+	header := <-received
+	if header.Get(IDHeader) != "delivery-1" || header.Get(EventHeader) != "chirp.created" {
+		t.Errorf("unexpected delivery headers %v", header)
+	}
+}
+
+func TestSenderFailures(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, failing.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	sender := newSender(nil)
+	tests := map[string]struct {
+		url    string
+		status int
+	}{
+		"error response": {failing.URL, http.StatusServiceUnavailable},
+		"redirect":       {redirecting.URL, http.StatusFound},
+		"unreachable":    {"http://127.0.0.1:1", 0},
+	}
+	for name, test := range tests {
+		status, err := sender.Send(context.Background(), Delivery{ID: "d", URL: test.url, Secret: "s", Payload: []byte(`{}`)})
+		if err == nil || status != test.status {
+			t.Errorf("%v: expected failure with status %v, got %v: %v", name, test.status, status, err)
+		}
+	}
+}
+
+func TestSenderRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no delivery to reach a loopback receiver")
+	}))
+	defer receiver.Close()
+
+	status, err := NewSender().Send(context.Background(), Delivery{ID: "d", URL: receiver.URL, Secret: "s", Payload: []byte(`{}`)})
+	if !errors.Is(err, ErrPrivateAddress) || status != 0 {
+		t.Errorf("expected the loopback receiver to be refused, got %v: %v", status, err)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	expected := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fc00::1":              false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	}
+	for addr, public := range expected {
+		if got := PublicAddress(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("PublicAddress(%v) = %v, expected %v", addr, got, public)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	expected := map[int]time.Duration{
+		0:   30 * time.Second,
+		1:   30 * time.Second,
+		2:   time.Minute,
+		5:   8 * time.Minute,
+		9:   128 * time.Minute,
+		11:  6 * time.Hour,
+		100: 6 * time.Hour,
+	}
+	for attempts, delay := range expected {
+		if got := Backoff(attempts); got != delay {
+			t.Errorf("after %v attempts expected %v, got %v", attempts, delay, got)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// run a job on a fixed interval for the life of the process
+func runEvery(interval time.Duration, name string, job func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := job(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("Background job %v failed: %v", name, err)
+			}
+		}
+	}()
+}
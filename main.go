@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Lokee86/serverProject/internal/auth"
 	"github.com/Lokee86/serverProject/internal/database"
+	"github.com/Lokee86/serverProject/internal/entitlements"
+	"github.com/Lokee86/serverProject/internal/mailer"
+	"github.com/Lokee86/serverProject/internal/oidc"
+	"github.com/Lokee86/serverProject/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -21,19 +28,65 @@ func createServer(apiCfg *apiConfig) *http.Server {
 	handler := http.StripPrefix("/app/", http.FileServer(http.Dir(pathRoot)))
 	router.Handle("/app/", apiCfg.serverHitCounter(handler))
 	router.Handle("/Assets/", handler)
-	router.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
 	router.HandleFunc("GET /api/healthz", healthCheck)
-	router.HandleFunc("POST /admin/reset", apiCfg.resetCounter)
-	router.HandleFunc("POST /api/chirps", apiCfg.validateChirp)
-	router.HandleFunc("GET /api/chirps/", apiCfg.fetchChirps)
-	router.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.fetchSingleChirp)
-	router.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirp)
+	router.HandleFunc("GET /.well-known/jwks.json", jwksHandler)
+	router.Handle("POST /api/chirps", apiCfg.requireAuth(requireScope(auth.ScopeChirpsWrite, apiCfg.validateChirp)))
+	router.Handle("GET /api/chirps/", apiCfg.optionalAuth(requireScope(auth.ScopeChirpsRead, apiCfg.fetchChirps)))
+	router.Handle("GET /api/chirps/{chirpID}", apiCfg.optionalAuth(requireScope(auth.ScopeChirpsRead, apiCfg.fetchSingleChirp)))
+	router.Handle("DELETE /api/chirps/{chirpID}", apiCfg.requireAuth(requireScope(auth.ScopeChirpsWrite, apiCfg.deleteChirp)))
 	router.HandleFunc("POST /api/users", apiCfg.createUserHandler)
-	router.HandleFunc("PUT /api/users", apiCfg.updateAccount)
+	router.Handle("PUT /api/users", apiCfg.requireAuth(requireScope(auth.ScopeAccountWrite, apiCfg.updateAccount)))
+	router.Handle("PATCH /api/users/me", apiCfg.requireAuth(requireScope(auth.ScopeAccountWrite, apiCfg.updateAccount)))
+	router.Handle("DELETE /api/users/me", apiCfg.requireLogin(apiCfg.deleteAccountHandler))
+	router.Handle("POST /api/users/me/export", apiCfg.requireLogin(apiCfg.exportAccountHandler))
+	router.Handle("GET /api/users/me/subscription", apiCfg.requireAuth(requireScope(auth.ScopeAccountRead, apiCfg.getSubscriptionHandler)))
+	router.Handle("GET /api/users/me/entitlements", apiCfg.requireAuth(requireScope(auth.ScopeAccountRead, apiCfg.getEntitlementsHandler)))
+	router.HandleFunc("POST /api/users/verify", apiCfg.verifyEmailHandler)
+	router.Handle("POST /api/users/verify/resend", apiCfg.requireAuth(requireScope(auth.ScopeAccountWrite, apiCfg.resendVerificationHandler)))
 	router.HandleFunc("POST /api/login", apiCfg.loginHandler)
+	router.HandleFunc("POST /api/login/magic", apiCfg.magicLinkHandler)
+	router.HandleFunc("POST /api/login/magic/verify", apiCfg.verifyMagicLinkHandler)
+	router.HandleFunc("GET /api/login/oidc", apiCfg.oidcLoginHandler)
+	router.HandleFunc("GET /api/login/oidc/callback", apiCfg.oidcCallbackHandler)
+	router.HandleFunc("POST /api/login/2fa", apiCfg.twoFactorLoginHandler)
+	router.Handle("POST /api/2fa/setup", apiCfg.requireLogin(apiCfg.twoFactorSetupHandler))
+	router.Handle("POST /api/2fa/confirm", apiCfg.requireLogin(apiCfg.twoFactorConfirmHandler))
+	router.Handle("POST /api/2fa/disable", apiCfg.requireLogin(apiCfg.twoFactorDisableHandler))
 	router.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
 	router.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
+	router.Handle("POST /api/logout", apiCfg.requireLogin(apiCfg.logoutHandler))
+	router.Handle("GET /api/sessions", apiCfg.requireLogin(apiCfg.listSessionsHandler))
+	router.Handle("DELETE /api/sessions/{sessionID}", apiCfg.requireLogin(apiCfg.revokeSessionHandler))
+	router.Handle("POST /api/sessions/revoke-all", apiCfg.requireLogin(apiCfg.revokeAllSessionsHandler))
+	router.Handle("POST /api/tokens", apiCfg.requireLogin(apiCfg.createTokenHandler))
+	router.Handle("GET /api/tokens", apiCfg.requireLogin(apiCfg.listTokensHandler))
+	router.Handle("DELETE /api/tokens/{tokenID}", apiCfg.requireLogin(apiCfg.revokeTokenHandler))
+	router.Handle("POST /api/oauth/clients", apiCfg.requireLogin(apiCfg.createOAuthClientHandler))
+	router.Handle("GET /api/oauth/clients", apiCfg.requireLogin(apiCfg.listOAuthClientsHandler))
+	router.Handle("DELETE /api/oauth/clients/{clientID}", apiCfg.requireLogin(apiCfg.deleteOAuthClientHandler))
+	router.Handle("GET /api/oauth/consents", apiCfg.requireLogin(apiCfg.listOAuthConsentsHandler))
+	router.Handle("DELETE /api/oauth/consents/{clientID}", apiCfg.requireLogin(apiCfg.revokeOAuthConsentHandler))
+	router.Handle("GET /oauth/authorize", apiCfg.requireLogin(apiCfg.authorizeHandler))
+	router.Handle("POST /oauth/authorize", apiCfg.requireLogin(apiCfg.authorizeDecisionHandler))
+	router.HandleFunc("POST /oauth/token", apiCfg.oauthTokenHandler)
+	router.HandleFunc("POST /oauth/revoke", apiCfg.oauthRevokeHandler)
+	router.HandleFunc("POST /oauth/introspect", apiCfg.oauthIntrospectHandler)
+	router.Handle("POST /api/webhooks", apiCfg.requireLogin(apiCfg.createWebhookEndpointHandler))
+	router.Handle("GET /api/webhooks", apiCfg.requireLogin(apiCfg.listWebhookEndpointsHandler))
+	router.Handle("DELETE /api/webhooks/{endpointID}", apiCfg.requireLogin(apiCfg.deleteWebhookEndpointHandler))
+	router.Handle("GET /api/webhooks/{endpointID}/deliveries", apiCfg.requireLogin(apiCfg.listWebhookDeliveriesHandler))
+	router.HandleFunc("POST /api/password/forgot", apiCfg.forgotPasswordHandler)
+	router.HandleFunc("POST /api/password/reset", apiCfg.resetPasswordHandler)
 	router.HandleFunc("POST /api/polka/webhooks", apiCfg.upgradeAccount)
+
+	// every /admin/ route goes through the admin policy
+	adminRouter := http.NewServeMux()
+	adminRouter.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
+	adminRouter.HandleFunc("POST /admin/reset", apiCfg.resetCounter)
+	adminRouter.HandleFunc("POST /admin/lockouts/clear", apiCfg.clearLockoutHandler)
+	adminRouter.HandleFunc("GET /admin/webhooks", apiCfg.listWebhookEventsHandler)
+	adminRouter.HandleFunc("POST /admin/webhooks/{eventID}/replay", apiCfg.replayWebhookEventHandler)
+	router.Handle("/admin/", apiCfg.requireRole(auth.RoleAdmin, adminRouter.ServeHTTP))
 	return &http.Server{
 		Addr:    port,
 		Handler: router,
@@ -48,14 +101,88 @@ func main() {
 	if err != nil {
 		log.Printf("Error Loading Database: %v", err)
 	}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(database.New(db), os.Args[1:]))
+	}
 	apiCfg := &apiConfig{}
-	apiCfg.databaseQueries = database.New(db)
+	apiCfg.store = database.New(db)
+	apiCfg.accessTokenDenylist = newAccessTokenDenylist(apiCfg.store)
 	server := createServer(apiCfg)
 	apiCfg.platform = os.Getenv("PLATFORM")
-	if auth.TokenSecret == "" {
+	auth.DefaultKeyring, err = auth.LoadKeyringFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading JWT signing keys: %v", err)
+	}
+	if auth.DefaultKeyring == nil && auth.TokenSecret == "" {
 		log.Fatal("JWT_SECRET is not set")
 	}
-	apiCfg.polkaKey = os.Getenv("POLKA_KEY")
+	if auth.TokenHashKey == "" && auth.TokenSecret == "" {
+		log.Fatal("TOKEN_HASH_KEY is not set")
+	}
+	err = auth.ConfigurePasswordHasherFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring password hashing: %v", err)
+	}
+	// POLKA_KEYS lists the current key first, then any previous key Polka may still be signing with
+	polkaKeys := webhook.KeysFromEnv(os.Getenv("POLKA_KEYS"))
+	if len(polkaKeys) == 0 {
+		polkaKeys = webhook.KeysFromEnv(os.Getenv("POLKA_KEY"))
+	}
+	apiCfg.polkaWebhook = webhook.NewVerifier(polkaKeys)
+	apiCfg.polkaRequireSignature = os.Getenv("POLKA_REQUIRE_SIGNATURE") == "true"
+	apiCfg.webhookSender = webhook.NewSender()
+	apiCfg.mailer, err = mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring mailer: %v", err)
+	}
+	apiCfg.publicURL = os.Getenv("PUBLIC_URL")
+	if apiCfg.publicURL == "" {
+		apiCfg.publicURL = "http://localhost" + port
+	}
+	apiCfg.appURL = os.Getenv("APP_URL")
+	if apiCfg.appURL == "" {
+		apiCfg.appURL = apiCfg.publicURL + "/app"
+	}
+	apiCfg.oidcProvider, err = oidc.FromEnv(apiCfg.publicURL + "/api/login/oidc/callback")
+	if err != nil {
+		log.Fatalf("Error configuring single sign-on: %v", err)
+	}
+	apiCfg.entitlements, err = entitlements.FromEnv()
+	if err != nil {
+		log.Fatalf("Error loading entitlements: %v", err)
+	}
+	apiCfg.deletionGracePeriod = defaultDeletionGracePeriod
+	if gracePeriod := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); gracePeriod != "" {
+		apiCfg.deletionGracePeriod, err = time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Fatalf("Error parsing ACCOUNT_DELETION_GRACE_PERIOD: %v", err)
+		}
+	}
+	apiCfg.verifiedEmailRequired = map[string]bool{}
+	for _, action := range strings.Split(os.Getenv("REQUIRE_VERIFIED_EMAIL"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			apiCfg.verifiedEmailRequired[action] = true
+		}
+	}
+	apiCfg.loginAccountThrottle = auth.NewLoginThrottle(accountFreeAttempts, accountLockoutThreshold, loginLockoutDuration)
+	apiCfg.loginIPThrottle = auth.NewLoginThrottle(ipFreeAttempts, ipLockoutThreshold, loginLockoutDuration)
+	apiCfg.accountEmailLimiter = auth.NewSendLimiter(accountEmailLimit, accountEmailWindow)
+	apiCfg.ipEmailLimiter = auth.NewSendLimiter(ipEmailLimit, ipEmailWindow)
+	runEvery(10*time.Minute, "prune login and email limits", func(ctx context.Context) error {
+		apiCfg.loginAccountThrottle.Prune()
+		apiCfg.loginIPThrottle.Prune()
+		apiCfg.accountEmailLimiter.Prune()
+		apiCfg.ipEmailLimiter.Prune()
+		return nil
+	})
+	err = apiCfg.accessTokenDenylist.Sync(context.Background())
+	if err != nil {
+		log.Printf("Error loading access token denylist: %v", err)
+	}
+	runEvery(time.Minute, "sync access token denylist", apiCfg.accessTokenDenylist.Sync)
+	runEvery(15*time.Second, "deliver webhooks", apiCfg.deliverWebhooks)
+	runEvery(10*time.Minute, "expire subscriptions", apiCfg.expireSubscriptions)
+	runEvery(time.Hour, "delete scheduled accounts", apiCfg.deleteScheduledAccounts)
 	log.Printf("Server running on Port%v from %v", port, pathRoot)
 	log.Fatal(server.ListenAndServe())
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/Lokee86/serverProject/internal/entitlements"
+	"github.com/Lokee86/serverProject/internal/mailer"
+	"github.com/Lokee86/serverProject/internal/store"
+)
+
+// a server over an in-memory store, configured the way main does apart from the
+// mailer, which keeps what it is sent, and the login throttles' clock, which only
+// moves when advance is called
+type testAPI struct {
+	*apiConfig
+	handler http.Handler
+	mailer  *mailer.MemoryMailer
+	advance func(time.Duration)
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	if auth.TokenSecret == "" {
+		auth.TokenSecret = "test-secret"
+		t.Cleanup(func() { auth.TokenSecret = "" })
+	}
+	now := time.Now()
+	clock := func() time.Time { return now }
+	memoryMailer := &mailer.MemoryMailer{}
+
+	apiCfg := &apiConfig{}
+	apiCfg.store = store.NewMemory()
+	apiCfg.accessTokenDenylist = newAccessTokenDenylist(apiCfg.store)
+	apiCfg.mailer = memoryMailer
+	apiCfg.publicURL = "http://localhost" + port
+	apiCfg.appURL = apiCfg.publicURL + "/app"
+	apiCfg.entitlements = entitlements.Defaults()
+	apiCfg.deletionGracePeriod = defaultDeletionGracePeriod
+	apiCfg.loginAccountThrottle = auth.NewLoginThrottle(accountFreeAttempts, accountLockoutThreshold, loginLockoutDuration)
+	apiCfg.loginIPThrottle = auth.NewLoginThrottle(ipFreeAttempts, ipLockoutThreshold, loginLockoutDuration)
+	apiCfg.accountEmailLimiter = auth.NewSendLimiter(accountEmailLimit, accountEmailWindow)
+	apiCfg.ipEmailLimiter = auth.NewSendLimiter(ipEmailLimit, ipEmailWindow)
+	apiCfg.loginAccountThrottle.Now = clock
+	apiCfg.loginIPThrottle.Now = clock
+	apiCfg.accountEmailLimiter.Now = clock
+	apiCfg.ipEmailLimiter.Now = clock
+
+	return &testAPI{
+		apiConfig: apiCfg,
+		handler:   createServer(apiCfg).Handler,
+		mailer:    memoryMailer,
+		advance:   func(d time.Duration) { now = now.Add(d) },
+	}
+}
+
+// send a JSON request, with a bearer token if one is given
+func (api *testAPI) do(t *testing.T, method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	authorization := ""
+	if bearer != "" {
+		authorization = "Bearer " + bearer
+	}
+	return api.doAuthorized(t, method, path, authorization, body)
+}
+
+// send a JSON request with the given Authorization header, if any
+func (api *testAPI) doAuthorized(t *testing.T, method, path, authorization string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encoding request body failed: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	response := httptest.NewRecorder()
+	api.handler.ServeHTTP(response, req)
+	return response
+}
+
+// create a user through the API
+func (api *testAPI) createUser(t *testing.T, email, password string) {
+	t.Helper()
+	response := api.do(t, http.MethodPost, "/api/users", "", handleUser{Email: email, Password: password})
+	if response.Code != http.StatusCreated {
+		t.Fatalf("creating %v: expected 201, got %v: %s", email, response.Code, response.Body)
+	}
+}
+
+func (api *testAPI) login(t *testing.T, email, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	return api.do(t, http.MethodPost, "/api/login", "", handleUser{Email: email, Password: password})
+}
+
+// wait for the background sender to hand the mailer a message with the given subject
+func (api *testAPI) waitForMessage(t *testing.T, subject string) mailer.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, msg := range api.mailer.Messages() {
+			if msg.Subject == subject {
+				return msg
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %q email was sent", subject)
+	return mailer.Message{}
+}
+
+// the ?token= value of the link in an email
+func linkToken(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(msg.Body, "?token=")
+	if !ok {
+		t.Fatalf("no link in email:\n%v", msg.Body)
+	}
+	return strings.Fields(rest)[0]
+}
+
+func decodeBody[T any](t *testing.T, response *httptest.ResponseRecorder) T {
+	t.Helper()
+	var decoded T
+	err := json.Unmarshal(response.Body.Bytes(), &decoded)
+	if err != nil {
+		t.Fatalf("decoding response %q failed: %v", response.Body, err)
+	}
+	return decoded
+}
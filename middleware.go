@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+)
+
+// reject requests without a valid access token, passing the caller on to next in the request context
+func (a *apiConfig) requireAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, r *http.Request) {
+		if !hasCredentials(r) {
+			unauthorized(response, "", "Unauthorized: Missing access token")
+			return
+		}
+		principal, err := a.authenticate(r)
+		if err == errInvalidCSRFToken {
+			errorResponse(response, http.StatusForbidden, "Forbidden: Missing or invalid CSRF token")
+			return
+		} else if err != nil {
+			log.Printf("Rejected access token: %v", err)
+			unauthorized(response, "invalid_token", "Unauthorized: Invalid access token")
+			return
+		}
+		next(response, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// identify the caller when credentials are sent, letting anonymous requests through
+func (a *apiConfig) optionalAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, r *http.Request) {
+		if !hasCredentials(r) {
+			next(response, r)
+			return
+		}
+		a.requireAuth(next).ServeHTTP(response, r)
+	})
+}
+
+// validate the credentials on a request and build its principal
+func (a *apiConfig) authenticate(r *http.Request) (*auth.Principal, error) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil && auth.IsPersonalAccessToken(apiKey) {
+		return a.authenticatePersonalAccessToken(r, apiKey)
+	}
+	userToken, fromCookie, err := auth.GetAccessToken(r)
+	if err != nil {
+		return nil, err
+	}
+	_, claims, err := a.validateAccessToken(r.Context(), userToken)
+	if err != nil {
+		return nil, err
+	}
+	principal, err := auth.PrincipalFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	// browsers attach cookies to cross-site requests, so state changes must prove they came from our frontend
+	if fromCookie && !auth.SafeMethod(r.Method) && !auth.ValidCSRFToken(principal.SessionID, r.Header.Get(auth.CSRFHeader)) {
+		return nil, errInvalidCSRFToken
+	}
+	return principal, nil
+}
+
+var errInvalidCSRFToken = errors.New("missing or invalid CSRF token")
+
+// build the principal for a personal access token, limited to the scopes it was created with
+func (a *apiConfig) authenticatePersonalAccessToken(r *http.Request, apiKey string) (*auth.Principal, error) {
+	pat, err := a.store.GetPersonalAccessToken(r.Context(), auth.HashToken(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	if pat.RevokedAt.Valid {
+		return nil, errors.New("personal access token revoked")
+	}
+	if pat.ExpiresAt.Valid && time.Now().After(pat.ExpiresAt.Time) {
+		return nil, errors.New("personal access token expired")
+	}
+	// an account waiting to be deleted can only be used again by signing in, which cancels the deletion
+	owner, err := a.store.GetUserByID(r.Context(), pat.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.DeletionScheduledAt.Valid {
+		return nil, errors.New("account is scheduled for deletion")
+	}
+	if err := a.store.TouchPersonalAccessToken(r.Context(), pat.ID); err != nil {
+		log.Printf("Error recording personal access token use: %v", err)
+	}
+	scopes := pat.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	principal := &auth.Principal{
+		UserID:  pat.UserID,
+		TokenID: pat.ID.String(),
+		// delegated credentials never carry elevated roles
+		Role:   auth.RoleUser,
+		Scopes: scopes,
+	}
+	if pat.ExpiresAt.Valid {
+		principal.ExpiresAt = pat.ExpiresAt.Time
+	}
+	return principal, nil
+}
+
+// reject authenticated callers whose credentials were not granted scope; anonymous callers pass through
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, r *http.Request) {
+		principal := currentPrincipal(r)
+		if principal != nil && !principal.HasScope(scope) {
+			response.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="chirpy", error="insufficient_scope", scope="%v"`, scope))
+			errorResponse(response, http.StatusForbidden, "Forbidden: Insufficient scope")
+			return
+		}
+		next(response, r)
+	}
+}
+
+// reject delegated credentials on endpoints that manage the account's sign-in and credentials
+func (a *apiConfig) requireLogin(next http.HandlerFunc) http.Handler {
+	return a.requireAuth(func(response http.ResponseWriter, r *http.Request) {
+		if currentPrincipal(r).Scopes != nil {
+			errorResponse(response, http.StatusForbidden, "Forbidden: Requires a signed in session")
+			return
+		}
+		next(response, r)
+	})
+}
+
+// reject callers whose role is less privileged than role
+func (a *apiConfig) requireRole(role string, next http.HandlerFunc) http.Handler {
+	return a.requireAuth(func(response http.ResponseWriter, r *http.Request) {
+		principal := currentPrincipal(r)
+		if !principal.HasRole(role) {
+			log.Printf("User %v with role %v denied access to %v", principal.UserID, principal.Role, r.URL.Path)
+			errorResponse(response, http.StatusForbidden, "Forbidden: Insufficient role")
+			return
+		}
+		next(response, r)
+	})
+}
+
+// the caller of a request that went through requireAuth or optionalAuth; nil when anonymous
+func currentPrincipal(r *http.Request) *auth.Principal {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	return principal
+}
+
+// send a 401 with an RFC 6750 WWW-Authenticate challenge; errorCode is empty when no credentials were sent
+func unauthorized(response http.ResponseWriter, errorCode, mesg string) {
+	challenge := `Bearer realm="chirpy"`
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error="%v"`, errorCode)
+	}
+	response.Header().Set("WWW-Authenticate", challenge)
+	errorResponse(response, http.StatusUnauthorized, mesg)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Lokee86/serverProject/internal/auth"
+	"github.com/google/uuid"
+)
+
+type cookieSessionKey struct{}
+
+// report whether a login should hand its tokens over as cookies, asked for with ?mode=cookie
+func cookieSessionRequested(r *http.Request) bool {
+	requested, _ := r.Context().Value(cookieSessionKey{}).(bool)
+	return requested || r.URL.Query().Get("mode") == "cookie"
+}
+
+// mark a login as a cookie session when the mode was chosen on an earlier request, as with single sign-on
+func withCookieSession(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), cookieSessionKey{}, true))
+}
+
+// store a session's tokens in HttpOnly cookies, along with the CSRF token the frontend must echo on unsafe requests
+func (a *apiConfig) setSessionCookies(response http.ResponseWriter, accessToken, refreshToken string, sessionID uuid.UUID) {
+	secure := a.secureCookies()
+	a.setAccessTokenCookie(response, accessToken)
+	http.SetCookie(response, &http.Cookie{
+		Name:  auth.RefreshTokenCookie,
+		Value: refreshToken,
+		// only ever sent to the endpoint that needs it
+		Path:     "/api/refresh",
+		MaxAge:   int(refreshTokenLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(response, &http.Cookie{
+		Name:     auth.CSRFCookie,
+		Value:    auth.CSRFToken(sessionID),
+		Path:     "/",
+		MaxAge:   int(refreshTokenLifetime.Seconds()),
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// replace the access token cookie alone, leaving the session's refresh token in place
+func (a *apiConfig) setAccessTokenCookie(response http.ResponseWriter, accessToken string) {
+	http.SetCookie(response, &http.Cookie{
+		Name:     auth.AccessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   int(accessTokenLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// cookies are marked Secure unless Chirpy is served over plain http, as in local development
+func (a *apiConfig) secureCookies() bool {
+	return strings.HasPrefix(a.publicURL, "https://")
+}
+
+// remove every session cookie from the browser
+func clearSessionCookies(response http.ResponseWriter) {
+	for name, path := range map[string]string{
+		auth.AccessTokenCookie:  "/",
+		auth.RefreshTokenCookie: "/api/refresh",
+		auth.CSRFCookie:         "/",
+	} {
+		http.SetCookie(response, &http.Cookie{Name: name, Path: path, MaxAge: -1})
+	}
+}
+
+// report whether a request carries credentials in either the Authorization header or the access token cookie
+func hasCredentials(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	cookie, err := r.Cookie(auth.AccessTokenCookie)
+	return err == nil && cookie.Value != ""
+}
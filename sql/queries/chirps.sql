@@ -33,4 +33,9 @@ WHERE id = $1;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-where id = $1;
\ No newline at end of file
+where id = $1;
+
+-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+  AND created_at > $2;
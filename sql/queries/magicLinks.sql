@@ -0,0 +1,23 @@
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links (
+    token_hash,
+    user_id,
+    email,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: UseMagicLink :one
+UPDATE magic_links
+SET used_at = NOW()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > NOW()
+RETURNING *;
+
+-- name: InvalidateMagicLinks :exec
+UPDATE magic_links
+SET used_at = NOW()
+WHERE user_id = $1
+  AND used_at IS NULL;
@@ -0,0 +1,82 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (user_id, name, secret_hash, redirect_uris, scopes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClients :many
+SELECT *
+FROM oauth_clients
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (
+    code_hash,
+    client_id,
+    user_id,
+    redirect_uri,
+    scopes,
+    code_challenge,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: GetAuthorizationCode :one
+SELECT *
+FROM oauth_authorization_codes
+WHERE code_hash = $1;
+
+-- name: RedeemAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW(),
+    family_id = $2
+WHERE code_hash = $1
+  AND used_at IS NULL
+RETURNING *;
+
+-- name: InvalidateAuthorizationCodes :exec
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND client_id = $2
+  AND used_at IS NULL;
+
+-- name: UpsertOAuthConsent :exec
+INSERT INTO oauth_consents (user_id, client_id, scopes)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, client_id)
+DO UPDATE SET scopes = EXCLUDED.scopes,
+    updated_at = NOW();
+
+-- name: GetOAuthConsent :one
+SELECT *
+FROM oauth_consents
+WHERE user_id = $1
+  AND client_id = $2;
+
+-- name: ListOAuthConsents :many
+SELECT oauth_consents.client_id,
+    oauth_clients.name,
+    oauth_consents.scopes,
+    oauth_consents.created_at,
+    oauth_consents.updated_at
+FROM oauth_consents
+JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id
+WHERE oauth_consents.user_id = $1
+ORDER BY oauth_consents.updated_at DESC;
+
+-- name: DeleteOAuthConsent :execrows
+DELETE FROM oauth_consents
+WHERE user_id = $1
+  AND client_id = $2;
@@ -0,0 +1,26 @@
+-- name: CreateRecoveryToken :exec
+INSERT INTO recovery_tokens (
+    token_hash,
+    user_id,
+    expires_at
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: GetRecoveryToken :one
+SELECT *
+FROM recovery_tokens
+WHERE token_hash = $1;
+
+-- name: UseRecoveryToken :execrows
+UPDATE recovery_tokens
+SET used_at = NOW()
+WHERE id = $1
+  AND used_at IS NULL
+  AND expires_at > NOW();
+
+-- name: InvalidateRecoveryTokens :exec
+UPDATE recovery_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+  AND used_at IS NULL;
@@ -1,19 +1,84 @@
 -- name: CreateRefreshToken :exec
 INSERT INTO refresh_tokens (
-    token,
+    token_hash,
     user_id,
-    expires_at
+    expires_at,
+    family_id,
+    user_agent,
+    ip_address,
+    client_id,
+    scopes
 ) VALUES (
-    $1, $2, $3
+    $1, $2, $3, $4, $5, $6, $7, $8
 );
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(),
     updated_at = NOW()
-WHERE token = $1;
+WHERE id = $1;
 
 -- name: GetRefreshToken :one
 SELECT *
 FROM refresh_tokens
-WHERE token = $1;
+WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW(),
+    revoked_at = NOW(),
+    last_used_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = COALESCE(revoked_at, NOW()),
+    updated_at = NOW()
+WHERE family_id = $1;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: ListSessions :many
+SELECT family_id,
+    MIN(created_at)::timestamp AS created_at,
+    MAX(COALESCE(last_used_at, created_at))::timestamp AS last_used_at,
+    (ARRAY_AGG(user_agent ORDER BY created_at DESC))[1]::text AS user_agent,
+    (ARRAY_AGG(ip_address ORDER BY created_at DESC))[1]::text AS ip_address,
+    MAX(expires_at)::timestamp AS expires_at
+FROM refresh_tokens
+WHERE user_id = $1
+  AND client_id IS NULL
+GROUP BY family_id
+HAVING BOOL_OR(revoked_at IS NULL AND expires_at > NOW())
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+  AND family_id <> $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeClientRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+  AND client_id = $2
+  AND revoked_at IS NULL;
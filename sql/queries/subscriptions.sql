@@ -0,0 +1,55 @@
+-- name: StartSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_start, current_period_end)
+VALUES ($1, $2, 'active', $3, $4)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    cancel_at_period_end = FALSE,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET cancel_at_period_end = TRUE,
+    canceled_at = COALESCE(canceled_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+  AND status IN ('active', 'past_due');
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due',
+    updated_at = NOW()
+WHERE user_id = $1
+  AND status = 'active';
+
+-- name: RefundSubscription :execrows
+UPDATE subscriptions
+SET status = 'refunded',
+    current_period_end = LEAST(current_period_end, NOW()),
+    canceled_at = COALESCE(canceled_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1;
+
+-- name: ExpireSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired',
+        updated_at = NOW()
+    WHERE status IN ('active', 'past_due')
+      AND current_period_end <= NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE,
+    updated_at = NOW()
+FROM expired
+WHERE users.id = expired.user_id
+RETURNING users.id;
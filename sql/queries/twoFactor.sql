@@ -0,0 +1,14 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO two_factor_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE two_factor_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM two_factor_recovery_codes
+WHERE user_id = $1;
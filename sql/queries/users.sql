@@ -18,12 +18,13 @@ SELECT * FROM users WHERE email = $1;
 -- name: GetUserByID :one
 SELECT * FROM users WHERE id = $1;
 
--- name: UpdateAccount :exec
+-- name: UpdateAccount :one
 UPDATE users
-SET email = $1,
-    hashed_password = $2,
+SET hashed_password = COALESCE(sqlc.narg('hashed_password'), hashed_password),
+    pending_email = COALESCE(sqlc.narg('pending_email'), pending_email),
     updated_at = NOW()
-WHERE id = $3;
+WHERE id = sqlc.arg('id')
+RETURNING *;
 
 -- name: ActivateChirpyRed :exec
 UPDATE users
@@ -36,3 +37,91 @@ UPDATE users
 SET is_chirpy_red = FALSE,
     updated_at = NOW()
 WHERE id = $1;
+
+-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: MarkEmailVerified :execrows
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND email = $2;
+
+-- name: SetPendingEmail :exec
+UPDATE users
+SET pending_email = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: ConfirmPendingEmail :execrows
+UPDATE users
+SET email = pending_email,
+    pending_email = NULL,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND pending_email = $2;
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $1,
+    totp_enabled_at = NULL,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(),
+    totp_last_step = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL,
+    totp_enabled_at = NULL,
+    totp_last_step = 0,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $1
+WHERE id = $2
+  AND totp_last_step < $1;
+
+-- name: RevokeTokensIssuedBefore :exec
+UPDATE users
+SET tokens_valid_after = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: GetTokensValidAfter :one
+SELECT tokens_valid_after FROM users WHERE id = $1;
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET role = $1,
+    updated_at = NOW()
+WHERE email = $2;
+
+-- name: ScheduleAccountDeletion :exec
+UPDATE users
+SET deletion_scheduled_at = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: CancelAccountDeletion :execrows
+UPDATE users
+SET deletion_scheduled_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND deletion_scheduled_at IS NOT NULL;
+
+-- name: DeleteScheduledAccounts :execrows
+DELETE FROM users
+WHERE deletion_scheduled_at <= NOW();
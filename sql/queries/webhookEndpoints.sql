@@ -0,0 +1,69 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (user_id, url, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- admins' endpoints hear about every user, everyone else's only about themselves
+INSERT INTO webhook_deliveries (endpoint_id, event_type, payload)
+SELECT webhook_endpoints.id, sqlc.arg('event_type')::text, sqlc.arg('payload')::jsonb
+FROM webhook_endpoints
+JOIN users ON users.id = webhook_endpoints.user_id
+WHERE sqlc.arg('event_type')::text = ANY(webhook_endpoints.events)
+  AND (webhook_endpoints.user_id = sqlc.arg('subject_user_id') OR users.role = 'admin');
+
+-- name: ClaimWebhookDeliveries :many
+-- due deliveries are leased for a few minutes so a crashed sender's work is picked up again
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + INTERVAL '5 minutes'
+FROM webhook_endpoints
+WHERE webhook_deliveries.endpoint_id = webhook_endpoints.id
+  AND webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING webhook_deliveries.id, webhook_deliveries.event_type, webhook_deliveries.payload,
+    webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_status_code = $2,
+    last_error = NULL,
+    delivered_at = NOW()
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT 100;
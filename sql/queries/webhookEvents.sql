@@ -0,0 +1,41 @@
+-- name: RecordWebhookEvent :one
+-- a redelivered event is only handed back for processing when its earlier attempt failed,
+-- or was abandoned in processing for longer than any attempt takes
+INSERT INTO webhook_events (provider, event_id, event_type, payload)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (provider, event_id) DO UPDATE
+SET status = 'processing',
+    attempts = webhook_events.attempts + 1,
+    processing_started_at = NOW()
+WHERE webhook_events.status = 'failed'
+   OR (webhook_events.status = 'processing'
+       AND webhook_events.processing_started_at < NOW() - INTERVAL '5 minutes')
+RETURNING *;
+
+-- name: RetryWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing',
+    attempts = attempts + 1,
+    processing_started_at = NOW()
+WHERE id = $1
+  AND (status = 'failed'
+       OR (status = 'processing' AND processing_started_at < NOW() - INTERVAL '5 minutes'))
+RETURNING *;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET status = $2,
+    last_error = $3,
+    processed_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.narg('status')::text IS NULL
+   OR status = sqlc.narg('status')::text
+ORDER BY received_at DESC
+LIMIT 100;
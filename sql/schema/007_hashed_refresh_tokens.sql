@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN token_hash TEXT;
+
+-- the signing key is not available here, so existing tokens get an unkeyed
+-- SHA-256 hash; they are replaced with keyed hashes on their next rotation
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(token::bytea), 'hex');
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN token_hash SET NOT NULL,
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN token,
+ADD PRIMARY KEY (id),
+ADD CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash);
+
+-- +goose Down
+-- raw tokens cannot be recovered from their hashes, so every session is dropped
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_token_hash_key,
+DROP CONSTRAINT refresh_tokens_pkey,
+DROP COLUMN token_hash,
+DROP COLUMN id,
+ADD COLUMN token TEXT PRIMARY KEY;
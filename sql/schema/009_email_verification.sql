@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP,
+ADD COLUMN pending_email TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN pending_email,
+DROP COLUMN email_verified_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE revoked_access_tokens (
+    jti UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users
+ADD COLUMN tokens_valid_after TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN tokens_valid_after;
+
+DROP TABLE revoked_access_tokens;
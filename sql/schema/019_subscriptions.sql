@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('active', 'past_due', 'expired', 'refunded')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+    canceled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end)
+WHERE status IN ('active', 'past_due');
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- when the latest attempt at an event began, so one abandoned by a crash can be taken up again
+ALTER TABLE webhook_events
+ADD COLUMN processing_started_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN processing_started_at;